@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HasCondition reports whether pod already carries a True condition of the
+// given type, so callers can avoid issuing a no-op status update. It is
+// exported so the kubelet-side Agent (pkg/kubelet/dnspolicy), which is the
+// only thing that can truthfully confirm enforcement, can use the same
+// condition bookkeeping as this controller.
+func HasCondition(pod *v1.Pod, conditionType string) bool {
+	for _, c := range pod.Status.Conditions {
+		if string(c.Type) == conditionType && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearConditions removes both the Enforcing and Degraded conditions from
+// pod. Callers use this once a pod is no longer selected by any DNSPolicy,
+// so a stale condition doesn't outlive the enforcement it once described.
+func ClearConditions(pod *v1.Pod) {
+	conditions := make([]v1.PodCondition, 0, len(pod.Status.Conditions))
+	for _, c := range pod.Status.Conditions {
+		if string(c.Type) == ConditionEnforcing || string(c.Type) == ConditionDegraded {
+			continue
+		}
+		conditions = append(conditions, c)
+	}
+	pod.Status.Conditions = conditions
+}
+
+// SetCondition sets the given DNSPolicy condition type to True on pod,
+// clearing the complementary Enforcing/Degraded condition if present.
+func SetCondition(pod *v1.Pod, conditionType string) {
+	other := ConditionDegraded
+	if conditionType == ConditionDegraded {
+		other = ConditionEnforcing
+	}
+
+	now := metav1.Now()
+	conditions := make([]v1.PodCondition, 0, len(pod.Status.Conditions)+1)
+	for _, c := range pod.Status.Conditions {
+		if string(c.Type) == other || string(c.Type) == conditionType {
+			continue
+		}
+		conditions = append(conditions, c)
+	}
+	conditions = append(conditions, v1.PodCondition{
+		Type:               v1.PodConditionType(conditionType),
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: now,
+		Reason:             conditionType,
+	})
+	pod.Status.Conditions = conditions
+}
@@ -0,0 +1,393 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dnspolicy implements the controller that enforces
+// networking.k8s.io DNSPolicy objects. It watches DNSPolicy and Pod
+// objects, computes, per node, the set of pods selected by each policy
+// along with their merged allowed-domain sets, and publishes that
+// computation for the node-local dataplane agent (pkg/kubelet/dnspolicy)
+// to enforce by writing it onto each covered pod's
+// AllowedDomainsAnnotation, which the agent's own Pod watch picks up.
+package dnspolicy
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/apis/networking"
+	networkinginformers "k8s.io/kubernetes/pkg/client/informers/networking/v1alpha1"
+	networkinglisters "k8s.io/kubernetes/pkg/client/listers/networking/v1alpha1"
+)
+
+const (
+	// ConditionEnforcing is set on a Pod's DNSPolicy status when the
+	// node-local agent has successfully programmed enforcement for it.
+	ConditionEnforcing = "Enforcing"
+	// ConditionDegraded is set when the node-local agent failed to program
+	// enforcement, or the node that the pod is on hasn't reported back yet.
+	ConditionDegraded = "Degraded"
+
+	// ReasonQueryBlocked is used on events recorded against a pod when a
+	// DNS query was blocked by the node-local agent because it did not
+	// match any allowed domain.
+	ReasonQueryBlocked = "DNSQueryBlocked"
+)
+
+// PodDomains is the computed enforcement state for a single pod: the union
+// of allowedDomains across every DNSPolicy that selects it.
+type PodDomains struct {
+	PodUID         string
+	PodIP          string
+	Namespace      string
+	Name           string
+	AllowedDomains []string
+	HostNetwork    bool
+}
+
+// NodeState is what the controller publishes for a single node: the full
+// set of pods on that node which are subject to DNS enforcement, keyed by
+// pod UID. Host-network pods are never included, per DNSPolicySpec's
+// documented exclusion.
+type NodeState struct {
+	Node string
+	Pods map[string]PodDomains
+}
+
+// Controller watches DNSPolicy and Pod objects and computes, per node, the
+// merged enforcement state that the kubelet-side agent programs into the
+// node's dataplane (CoreDNS allowlist plugin or an eBPF socket filter).
+type Controller struct {
+	client kubernetes.Interface
+
+	dnsPolicyLister networkinglisters.DNSPolicyLister
+	dnsPolicySynced cache.InformerSynced
+
+	podLister corelisters.PodLister
+	podSynced cache.InformerSynced
+
+	recorder record.EventRecorder
+
+	queue workqueue.RateLimitingInterface
+
+	// nodeState holds the last state computed per node. The per-pod
+	// AllowedDomains it holds are published to the kubelet-side agent via
+	// each pod's AllowedDomainsAnnotation (not through this field, which
+	// the agent has no access to); nodeState itself is only used for
+	// tests and for deciding whether a resync actually changed anything.
+	nodeState map[string]NodeState
+}
+
+// NewController returns a Controller ready to be Run.
+func NewController(
+	client kubernetes.Interface,
+	dnsPolicyInformer networkinginformers.DNSPolicyInformer,
+	podInformer coreinformers.PodInformer,
+) *Controller {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "dnspolicy-controller"})
+
+	c := &Controller{
+		client:          client,
+		dnsPolicyLister: dnsPolicyInformer.Lister(),
+		dnsPolicySynced: dnsPolicyInformer.Informer().HasSynced,
+		podLister:       podInformer.Lister(),
+		podSynced:       podInformer.Informer().HasSynced,
+		recorder:        recorder,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "dnspolicy"),
+		nodeState:       map[string]NodeState{},
+	}
+
+	dnsPolicyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueueAllNodes() },
+		UpdateFunc: func(old, cur interface{}) { c.enqueueAllNodes() },
+		DeleteFunc: func(obj interface{}) { c.enqueueAllNodes() },
+	})
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueuePod(obj) },
+		UpdateFunc: func(old, cur interface{}) { c.enqueuePod(cur) },
+		DeleteFunc: func(obj interface{}) { c.enqueuePod(obj) },
+	})
+
+	return c
+}
+
+func (c *Controller) enqueuePod(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	if pod.Spec.NodeName == "" {
+		return
+	}
+	c.queue.Add(pod.Spec.NodeName)
+}
+
+func (c *Controller) enqueueAllNodes() {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("listing pods to requeue nodes: %v", err))
+		return
+	}
+	seen := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || seen[pod.Spec.NodeName] {
+			continue
+		}
+		seen[pod.Spec.NodeName] = true
+		c.queue.Add(pod.Spec.NodeName)
+	}
+}
+
+// Run starts the controller's workers and blocks until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Info("Starting DNSPolicy controller")
+	defer klog.Info("Shutting down DNSPolicy controller")
+
+	if !cache.WaitForNamedCacheSync("dnspolicy", stopCh, c.dnsPolicySynced, c.podSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	node := key.(string)
+	if err := c.syncNode(node); err != nil {
+		utilruntime.HandleError(fmt.Errorf("syncing node %q: %v", node, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// syncNode recomputes the DNS enforcement state for every pod on node and,
+// if it changed, publishes it by updating each affected pod's DNSPolicy
+// status conditions.
+func (c *Controller) syncNode(node string) error {
+	policies, err := c.dnsPolicyLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	state := NodeState{Node: node, Pods: map[string]PodDomains{}}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node || pod.Spec.HostNetwork {
+			continue
+		}
+
+		var allowed []string
+		var selected bool
+		for _, policy := range policies {
+			if policy.Namespace != pod.Namespace {
+				continue
+			}
+			selector, err := labelSelectorFor(policy)
+			if err != nil {
+				utilruntime.HandleError(fmt.Errorf("invalid podSelector on DNSPolicy %s/%s: %v", policy.Namespace, policy.Name, err))
+				continue
+			}
+			if !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			selected = true
+			allowed = mergeAllowedDomains(allowed, policy.Spec.AllowedDomains)
+		}
+
+		if !selected {
+			continue
+		}
+
+		state.Pods[string(pod.UID)] = PodDomains{
+			PodUID:         string(pod.UID),
+			PodIP:          pod.Status.PodIP,
+			Namespace:      pod.Namespace,
+			Name:           pod.Name,
+			AllowedDomains: allowed,
+			HostNetwork:    pod.Spec.HostNetwork,
+		}
+	}
+
+	previous := c.nodeState[node]
+	if err := c.retractDroppedPods(previous, state); err != nil {
+		// Don't advance c.nodeState[node] on a failed retraction: the next
+		// sync must diff against the same previous state so pods it didn't
+		// get to (or failed on) are retried instead of being forgotten.
+		return err
+	}
+	c.nodeState[node] = state
+	return c.updatePodConditions(state)
+}
+
+// retractDroppedPods clears AllowedDomainsAnnotation and the Enforcing/
+// Degraded conditions from any pod that was covered by previous but is no
+// longer covered by current, e.g. because the DNSPolicy that selected it
+// was deleted or narrowed, or the pod was relabeled out of its selector.
+// Without this, NodeStateSource.resync (pkg/kubelet/dnspolicy) would keep
+// re-publishing stale enforcement for a pod this controller has already
+// stopped tracking.
+func (c *Controller) retractDroppedPods(previous, current NodeState) error {
+	for uid, pd := range previous.Pods {
+		if _, stillCovered := current.Pods[uid]; stillCovered {
+			continue
+		}
+
+		pod, err := c.podLister.Pods(pd.Namespace).Get(pd.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			// Propagate rather than swallow: syncNode only advances
+			// c.nodeState[node] once retractDroppedPods returns nil, so
+			// returning here keeps this pod in "previous" for a retry
+			// instead of silently forgetting it.
+			return fmt.Errorf("getting pod %s/%s to retract enforcement: %w", pd.Namespace, pd.Name, err)
+		}
+
+		updated := pod.DeepCopy()
+		_, hadAnnotation := updated.Annotations[AllowedDomainsAnnotation]
+		delete(updated.Annotations, AllowedDomainsAnnotation)
+		if hadAnnotation {
+			updated, err = c.client.CoreV1().Pods(updated.Namespace).Update(updated)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !HasCondition(updated, ConditionEnforcing) && !HasCondition(updated, ConditionDegraded) {
+			continue
+		}
+		statusUpdate := updated.DeepCopy()
+		ClearConditions(statusUpdate)
+		if _, err := c.client.CoreV1().Pods(statusUpdate.Namespace).UpdateStatus(statusUpdate); err != nil {
+			return err
+		}
+		c.recorder.Eventf(statusUpdate, v1.EventTypeNormal, "DNSPolicyRetracted", "pod is no longer covered by any DNSPolicy, enforcement has been removed")
+	}
+	return nil
+}
+
+// updatePodConditions publishes pd.AllowedDomains onto every covered pod
+// via AllowedDomainsAnnotation, which is the data the kubelet-side agent
+// actually watches and enforces, and marks every pod newly covered by a
+// DNSPolicy as Degraded, since this controller has no visibility into
+// whether the node it landed on is even running a dataplane agent, let
+// alone whether that agent has successfully programmed enforcement for it.
+// Only the kubelet-side Agent (pkg/kubelet/dnspolicy), which actually runs
+// the Enforcer, is allowed to flip a pod to Enforcing; this controller
+// must never claim enforcement itself.
+func (c *Controller) updatePodConditions(state NodeState) error {
+	for _, pd := range state.Pods {
+		pod, err := c.podLister.Pods(pd.Namespace).Get(pd.Name)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("getting pod %s/%s: %v", pd.Namespace, pd.Name, err))
+			continue
+		}
+
+		desiredAnnotation, err := EncodeAllowedDomains(pd.AllowedDomains)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("encoding allowed domains for pod %s/%s: %v", pd.Namespace, pd.Name, err))
+			continue
+		}
+		conditionSet := HasCondition(pod, ConditionEnforcing) || HasCondition(pod, ConditionDegraded)
+		if pod.Annotations[AllowedDomainsAnnotation] == desiredAnnotation && conditionSet {
+			continue
+		}
+
+		if pod.Annotations[AllowedDomainsAnnotation] != desiredAnnotation {
+			updated := pod.DeepCopy()
+			if updated.Annotations == nil {
+				updated.Annotations = map[string]string{}
+			}
+			updated.Annotations[AllowedDomainsAnnotation] = desiredAnnotation
+			updated, err = c.client.CoreV1().Pods(updated.Namespace).Update(updated)
+			if err != nil {
+				return err
+			}
+			pod = updated
+		}
+
+		if conditionSet {
+			continue
+		}
+		updated := pod.DeepCopy()
+		SetCondition(updated, ConditionDegraded)
+		if _, err := c.client.CoreV1().Pods(updated.Namespace).UpdateStatus(updated); err != nil {
+			return err
+		}
+		c.recorder.Eventf(updated, v1.EventTypeNormal, "DNSPolicyPending", "pod is now covered by a DNSPolicy, awaiting enforcement confirmation from its node")
+	}
+	return nil
+}
+
+func mergeAllowedDomains(existing, add []string) []string {
+	seen := map[string]bool{}
+	for _, d := range existing {
+		seen[d] = true
+	}
+	merged := existing
+	for _, d := range add {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		merged = append(merged, d)
+	}
+	return merged
+}
+
+func labelSelectorFor(policy *networking.DNSPolicy) (labels.Selector, error) {
+	return metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/apis/networking"
+	kubeinformers "k8s.io/kubernetes/pkg/client/informers/networking/v1alpha1"
+)
+
+// TestSyncNodeEnforcesExampleComOnly pins the controller's half of
+// enforcement: given a DNSPolicy allowing only "*.example.com" and a
+// selected pod, syncNode must compute a NodeState whose merged
+// AllowedDomains allow "foo.example.com" and block "google.com". The
+// dataplane half — actually resolving queries through that computed
+// state and observing NXDOMAIN for real — is covered end-to-end by
+// TestServerEnforcesExampleComOnly in pkg/kubelet/dnspolicy, which runs a
+// real Server and queries it with Go's own DNS resolver. A true
+// `nslookup`-against-a-live-kubelet test still belongs in
+// test/e2e/network and is not added here.
+func TestSyncNodeEnforcesExampleComOnly(t *testing.T) {
+	policy := &networking.DNSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-only"},
+		Spec: networking.DNSPolicySpec{
+			PodSelector:    metav1.LabelSelector{},
+			AllowedDomains: []string{"*.example.com"},
+		},
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-pod", UID: "test-uid"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.5"},
+	}
+
+	client := fake.NewSimpleClientset(pod)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatalf("seeding pod informer: %v", err)
+	}
+
+	dnsPolicyInformerFactory := kubeinformers.NewSharedInformerFactory(nil, 0)
+	dnsPolicyInformer := dnsPolicyInformerFactory.DNSPolicies()
+	if err := dnsPolicyInformer.Informer().GetIndexer().Add(policy); err != nil {
+		t.Fatalf("seeding DNSPolicy informer: %v", err)
+	}
+
+	c := NewController(client, dnsPolicyInformer, podInformer)
+
+	if err := c.syncNode("node-1"); err != nil {
+		t.Fatalf("syncNode: %v", err)
+	}
+
+	state, ok := c.nodeState["node-1"]
+	if !ok {
+		t.Fatalf("expected node state for node-1 to be published")
+	}
+	pd, ok := state.Pods[string(pod.UID)]
+	if !ok {
+		t.Fatalf("expected pod %s to be covered by the policy", pod.UID)
+	}
+
+	if !MatchesAllowedDomain("foo.example.com", pd.AllowedDomains) {
+		t.Errorf("expected foo.example.com to be allowed by %v", pd.AllowedDomains)
+	}
+	if MatchesAllowedDomain("google.com", pd.AllowedDomains) {
+		t.Errorf("expected google.com to be blocked by %v", pd.AllowedDomains)
+	}
+}
+
+// TestSyncNodePublishesAllowedDomainsAnnotation covers the actual
+// controller-to-kubelet publish path: syncNode must write the pod's merged
+// AllowedDomains onto AllowedDomainsAnnotation via the API, since that's
+// the only thing the node-local agent (pkg/kubelet/dnspolicy) has any way
+// to observe, and must keep republishing it if the merged set changes on a
+// later resync even though the pod's condition is already set.
+func TestSyncNodePublishesAllowedDomainsAnnotation(t *testing.T) {
+	policy := &networking.DNSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-only"},
+		Spec: networking.DNSPolicySpec{
+			PodSelector:    metav1.LabelSelector{},
+			AllowedDomains: []string{"*.example.com"},
+		},
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-pod", UID: "test-uid"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.5"},
+	}
+
+	client := fake.NewSimpleClientset(pod)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatalf("seeding pod informer: %v", err)
+	}
+
+	dnsPolicyInformerFactory := kubeinformers.NewSharedInformerFactory(nil, 0)
+	dnsPolicyInformer := dnsPolicyInformerFactory.DNSPolicies()
+	if err := dnsPolicyInformer.Informer().GetIndexer().Add(policy); err != nil {
+		t.Fatalf("seeding DNSPolicy informer: %v", err)
+	}
+
+	c := NewController(client, dnsPolicyInformer, podInformer)
+
+	if err := c.syncNode("node-1"); err != nil {
+		t.Fatalf("syncNode: %v", err)
+	}
+
+	updated, err := client.CoreV1().Pods("default").Get(pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod: %v", err)
+	}
+	domains, ok, err := AllowedDomainsFromPod(updated)
+	if err != nil {
+		t.Fatalf("decoding annotation: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected %s to be set, got annotations %v", AllowedDomainsAnnotation, updated.Annotations)
+	}
+	if !MatchesAllowedDomain("foo.example.com", domains) {
+		t.Errorf("expected the published annotation to allow foo.example.com, got %v", domains)
+	}
+
+	// A second policy widens the merged set; syncNode must republish even
+	// though the pod's condition was already set by the first sync.
+	policy2 := &networking.DNSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "widen"},
+		Spec: networking.DNSPolicySpec{
+			PodSelector:    metav1.LabelSelector{},
+			AllowedDomains: []string{"*.other.com"},
+		},
+	}
+	if err := dnsPolicyInformer.Informer().GetIndexer().Add(policy2); err != nil {
+		t.Fatalf("seeding second DNSPolicy: %v", err)
+	}
+	if err := podInformer.Informer().GetIndexer().Update(updated); err != nil {
+		t.Fatalf("updating pod informer: %v", err)
+	}
+
+	if err := c.syncNode("node-1"); err != nil {
+		t.Fatalf("second syncNode: %v", err)
+	}
+
+	final, err := client.CoreV1().Pods("default").Get(pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod: %v", err)
+	}
+	domains, ok, err = AllowedDomainsFromPod(final)
+	if err != nil {
+		t.Fatalf("decoding annotation: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected %s to still be set", AllowedDomainsAnnotation)
+	}
+	if !MatchesAllowedDomain("foo.other.com", domains) {
+		t.Errorf("expected the republished annotation to also allow foo.other.com, got %v", domains)
+	}
+}
+
+// TestSyncNodeRetractsDroppedPod covers the removal path: once a pod is no
+// longer selected by any DNSPolicy (here, because the policy is deleted),
+// syncNode must clear AllowedDomainsAnnotation and the Enforcing/Degraded
+// conditions it previously published, rather than leaving them to be
+// re-enforced forever by NodeStateSource.resync (pkg/kubelet/dnspolicy).
+func TestSyncNodeRetractsDroppedPod(t *testing.T) {
+	policy := &networking.DNSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-only"},
+		Spec: networking.DNSPolicySpec{
+			PodSelector:    metav1.LabelSelector{},
+			AllowedDomains: []string{"*.example.com"},
+		},
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-pod", UID: "test-uid"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.5"},
+	}
+
+	client := fake.NewSimpleClientset(pod)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatalf("seeding pod informer: %v", err)
+	}
+
+	dnsPolicyInformerFactory := kubeinformers.NewSharedInformerFactory(nil, 0)
+	dnsPolicyInformer := dnsPolicyInformerFactory.DNSPolicies()
+	if err := dnsPolicyInformer.Informer().GetIndexer().Add(policy); err != nil {
+		t.Fatalf("seeding DNSPolicy informer: %v", err)
+	}
+
+	c := NewController(client, dnsPolicyInformer, podInformer)
+
+	if err := c.syncNode("node-1"); err != nil {
+		t.Fatalf("syncNode: %v", err)
+	}
+	covered, err := client.CoreV1().Pods("default").Get(pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod: %v", err)
+	}
+	if _, ok, _ := AllowedDomainsFromPod(covered); !ok {
+		t.Fatalf("expected %s to be set before the policy is removed", AllowedDomainsAnnotation)
+	}
+	if !HasCondition(covered, ConditionDegraded) {
+		t.Fatalf("expected Degraded to be set before the policy is removed")
+	}
+
+	// Delete the policy, so the pod is no longer selected by anything, and
+	// resync.
+	if err := dnsPolicyInformer.Informer().GetIndexer().Delete(policy); err != nil {
+		t.Fatalf("removing DNSPolicy: %v", err)
+	}
+	if err := podInformer.Informer().GetIndexer().Update(covered); err != nil {
+		t.Fatalf("updating pod informer: %v", err)
+	}
+
+	if err := c.syncNode("node-1"); err != nil {
+		t.Fatalf("second syncNode: %v", err)
+	}
+
+	final, err := client.CoreV1().Pods("default").Get(pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod: %v", err)
+	}
+	if _, ok, _ := AllowedDomainsFromPod(final); ok {
+		t.Errorf("expected %s to be cleared once the pod is no longer covered, got annotations %v", AllowedDomainsAnnotation, final.Annotations)
+	}
+	if HasCondition(final, ConditionDegraded) || HasCondition(final, ConditionEnforcing) {
+		t.Errorf("expected Enforcing/Degraded to be cleared once the pod is no longer covered, got %v", final.Status.Conditions)
+	}
+	if _, ok := c.nodeState["node-1"].Pods[string(pod.UID)]; ok {
+		t.Errorf("expected node state to stop tracking the pod once it's no longer covered")
+	}
+}
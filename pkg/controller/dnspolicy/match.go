@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import "strings"
+
+// MatchesAllowedDomain reports whether the fully qualified domain name queried
+// by a pod (query) is permitted by the given allowedDomains list, as documented
+// on networking.v1alpha1.DNSPolicySpec.AllowedDomains:
+//
+//   - entries may be exact FQDNs (e.g. "www.example.com")
+//   - entries may use "*" to wildcard one or more of the left-most labels
+//     (e.g. "*.example.com" or "*.*.example.com")
+//   - a query only matches a wildcard entry if it has the same number of
+//     dot-separated labels; "foo.bar.example.com" does not match "*.example.com"
+//
+// Both query and allowedDomains entries are compared case-insensitively and
+// with any trailing dot stripped.
+func MatchesAllowedDomain(query string, allowedDomains []string) bool {
+	queryLabels := splitFQDN(query)
+	for _, domain := range allowedDomains {
+		if matchesDomain(queryLabels, splitFQDN(domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesDomain(queryLabels, domainLabels []string) bool {
+	if len(queryLabels) != len(domainLabels) {
+		return false
+	}
+	for i, label := range domainLabels {
+		if label == "*" {
+			continue
+		}
+		if label != queryLabels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitFQDN(name string) []string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return strings.Split(name, ".")
+}
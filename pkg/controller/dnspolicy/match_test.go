@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import "testing"
+
+func TestMatchesAllowedDomain(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		allowedDomains []string
+		want           bool
+	}{
+		{
+			name:           "exact match",
+			query:          "www.example.com",
+			allowedDomains: []string{"www.example.com"},
+			want:           true,
+		},
+		{
+			name:           "wildcard matches single label",
+			query:          "foo.example.com",
+			allowedDomains: []string{"*.example.com"},
+			want:           true,
+		},
+		{
+			name:           "wildcard does not match extra labels",
+			query:          "foo.bar.example.com",
+			allowedDomains: []string{"*.example.com"},
+			want:           false,
+		},
+		{
+			name:           "double wildcard matches two labels",
+			query:          "foo.bar.example.com",
+			allowedDomains: []string{"*.*.example.com"},
+			want:           true,
+		},
+		{
+			name:           "unrelated domain blocked",
+			query:          "google.com",
+			allowedDomains: []string{"*.example.com"},
+			want:           false,
+		},
+		{
+			name:           "trailing dot and case are ignored",
+			query:          "FOO.Example.com.",
+			allowedDomains: []string{"*.example.com"},
+			want:           true,
+		},
+		{
+			name:           "empty allowedDomains blocks everything",
+			query:          "foo.example.com",
+			allowedDomains: nil,
+			want:           false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesAllowedDomain(tt.query, tt.allowedDomains); got != tt.want {
+				t.Errorf("MatchesAllowedDomain(%q, %v) = %v, want %v", tt.query, tt.allowedDomains, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// AllowedDomainsAnnotation carries a pod's merged, JSON-encoded
+// AllowedDomains, as computed by the controller's syncNode. The Enforcing/
+// Degraded conditions this controller and the kubelet-side Agent set only
+// say whether enforcement is active; they carry no domain data, so this
+// annotation is the actual publish path the node-local agent watches (via
+// its own Pod informer) to learn what to enforce.
+const AllowedDomainsAnnotation = "networking.k8s.io/dns-policy-allowed-domains"
+
+// EncodeAllowedDomains JSON-encodes domains for storage in
+// AllowedDomainsAnnotation.
+func EncodeAllowedDomains(domains []string) (string, error) {
+	b, err := json.Marshal(domains)
+	if err != nil {
+		return "", fmt.Errorf("encoding allowed domains: %w", err)
+	}
+	return string(b), nil
+}
+
+// AllowedDomainsFromPod decodes the AllowedDomains a controller has
+// published for pod, reporting ok=false if pod carries no
+// AllowedDomainsAnnotation (e.g. it isn't covered by any DNSPolicy).
+func AllowedDomainsFromPod(pod *v1.Pod) (domains []string, ok bool, err error) {
+	raw, present := pod.Annotations[AllowedDomainsAnnotation]
+	if !present {
+		return nil, false, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &domains); err != nil {
+		return nil, false, fmt.Errorf("decoding %s on pod %s/%s: %w", AllowedDomainsAnnotation, pod.Namespace, pod.Name, err)
+	}
+	return domains, true, nil
+}
@@ -58,20 +58,30 @@ var metadataHeader = &http.Header{
 	"Metadata-Flavor": []string{"Google"},
 }
 
+// metadataProvider is embedded by the provider structs below to give them a
+// shared Enabled implementation: all of them are only available when
+// running on a GCE VM (a containerRegistryProvider further narrows this
+// with its own Enabled, which shadows this one).
+type metadataProvider struct{}
+
 // A DockerConfigProvider that reads its configuration from a specific
 // Google Compute Engine metadata key: 'google-dockercfg'.
 type dockerConfigKeyProvider struct {
+	metadataProvider
 }
 
 // A DockerConfigProvider that reads its configuration from a URL read from
 // a specific Google Compute Engine metadata key: 'google-dockercfg-url'.
 type dockerConfigURLKeyProvider struct {
+	metadataProvider
 }
 
 // A DockerConfigProvider that provides a dockercfg with:
-//    Username: "_token"
-//    Password: "{access token from metadata}"
+//
+//	Username: "_token"
+//	Password: "{access token from metadata}"
 type containerRegistryProvider struct {
+	metadataProvider
 }
 
 // init registers the various means by which credentials may
@@ -118,7 +128,7 @@ func (g *dockerConfigKeyProvider) Provide(image string) credentialprovider.Docke
 	gcpCfg := gcpcredentials.ProvideDockerConfigKey(image)
 
 	for key, value := range gcpCfg {
-		entry := credentialprovder.DockerConfigEntry{
+		entry := credentialprovider.DockerConfigEntry{
 			Username: value.Username,
 			Password: value.Password,
 			Email:    value.Email,
@@ -135,7 +145,7 @@ func (g *dockerConfigURLKeyProvider) Provide(image string) credentialprovider.Do
 	gcpCfg := gcpcredentials.ProvideDockerConfigURLKey(image)
 
 	for key, value := range gcpCfg {
-		entry := credentialprovder.DockerConfigEntry{
+		entry := credentialprovider.DockerConfigEntry{
 			Username: value.Username,
 			Password: value.Password,
 			Email:    value.Email,
@@ -146,31 +156,46 @@ func (g *dockerConfigURLKeyProvider) Provide(image string) credentialprovider.Do
 	return cfg
 }
 
-// Enabled implements a special metadata-based check, which verifies the
-// storage scope is available on the GCE VM.
-// If running on a GCE VM, check if 'default' service account exists.
-// If it does not exist, assume that registry is not enabled.
-// If default service account exists, check if relevant scopes exist in the default service account.
-// The metadata service can become temporarily inaccesible. Hence all requests to the metadata
-// service will be retried until the metadata server returns a `200`.
-// It is expected that "http://metadata.google.internal./computeMetadata/v1/instance/service-accounts/" will return a `200`
-// and "http://metadata.google.internal./computeMetadata/v1/instance/service-accounts/default/scopes" will also return `200`.
-// More information on metadata service can be found here - https://cloud.google.com/compute/docs/storing-retrieving-metadata
+// Enabled reports whether credentials are available for pulling from a
+// Google container registry. It checks gcpcredentials.DefaultContextStore
+// first, so a GKE Workload Identity projected token is preferred over the
+// node's ambient credentials on a hybrid node where both apply, and falls
+// back to ambient credentials resolved through cloud.google.com/go/auth
+// (an external-account JSON file, impersonation, or
+// GOOGLE_APPLICATION_CREDENTIALS) or, on a bare GCE VM with none of those
+// configured, a node service account with the storage scope. See
+// gcpcredentials.ContainerRegistryEnabled for the metadata-server fallback
+// details.
 func (g *containerRegistryProvider) Enabled() bool {
-	if !gcpcredentials.OnGCEVM() {
-		return false
+	if _, ok := gcpcredentials.DefaultContextStore.Detect(); ok {
+		return true
 	}
-
-	return gcpcredentials.HasStorageScope()
+	return gcpcredentials.ContainerRegistryEnabled()
 }
 
-// Provide implements DockerConfigProvider
+// Provide implements DockerConfigProvider. It prefers whichever context
+// gcpcredentials.DefaultContextStore detects (e.g. "gke" on a Workload
+// Identity node), so the same binary works on a GCE VM, a GKE Autopilot
+// node, or a hybrid cluster without recompiling, and falls back to the
+// legacy ambient-credentials/metadata-server resolution when no context
+// in the store applies.
 func (g *containerRegistryProvider) Provide(image string) credentialprovider.DockerConfig {
 	cfg := credentialprovider.DockerConfig{}
-	gcpCfg := gcpcredentials.ProvideContainerRegistry(image)
 
+	if storeCfg := gcpcredentials.DefaultContextStore.Provide(image); len(storeCfg) > 0 {
+		for key, value := range storeCfg {
+			cfg[key] = credentialprovider.DockerConfigEntry{
+				Username: value.Username,
+				Password: value.Password,
+				Email:    value.Email,
+			}
+		}
+		return cfg
+	}
+
+	gcpCfg := gcpcredentials.ProvideContainerRegistry(image)
 	for key, value := range gcpCfg {
-		entry := credentialprovder.DockerConfigEntry{
+		entry := credentialprovider.DockerConfigEntry{
 			Username: value.Username,
 			Password: value.Password,
 			Email:    value.Email,
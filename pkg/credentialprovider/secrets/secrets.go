@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package secrets provides a DockerConfigProvider that resolves
+// kubernetes.io/dockerconfigjson and kubernetes.io/dockercfg Secrets,
+// alongside the GCE-metadata-based providers registered by
+// k8s.io/kubernetes/pkg/credentialprovider/gcp, so admins running private
+// DockerHub/Harbor/Quay images next to GCR on the same node can layer
+// secret credentials through the same DockerKeyring.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/credentialprovider"
+)
+
+// SecretLister lists the Secrets available in a namespace. It is satisfied
+// by a corev1 SecretLister backed by the kubelet's shared informer cache;
+// injected at startup so this package doesn't depend on client-go wiring.
+type SecretLister interface {
+	Secrets(namespace string) ([]*v1.Secret, error)
+}
+
+// SecretDockerConfigProvider resolves docker config entries from
+// dockerconfigjson/dockercfg Secrets in a single namespace, fixed at
+// construction and never mutated afterward. Unlike the GCE metadata
+// providers (see pkg/credentialprovider/gcp), which are process-wide
+// singletons because ambient credentials don't vary per pod, which
+// namespace's imagePullSecrets apply is per-pod and isn't otherwise part
+// of the DockerConfigProvider interface; build a new instance per pod (via
+// ProviderForNamespace) rather than sharing one across namespaces, or two
+// pulls for different tenants can race and one can observe the other's
+// namespace.
+type SecretDockerConfigProvider struct {
+	lister    SecretLister
+	namespace string
+}
+
+// NewSecretDockerConfigProvider returns a DockerConfigProvider that
+// resolves dockerconfigjson/dockercfg Secrets in namespace through lister.
+// Prefer ProviderForNamespace, which also wraps the result in the same
+// cache the GCE metadata providers use.
+func NewSecretDockerConfigProvider(lister SecretLister, namespace string) *SecretDockerConfigProvider {
+	return &SecretDockerConfigProvider{lister: lister, namespace: namespace}
+}
+
+// Enabled implements DockerConfigProvider. A provider constructed with an
+// empty namespace has nothing to offer.
+func (p *SecretDockerConfigProvider) Enabled() bool {
+	return p.namespace != ""
+}
+
+// Provide implements DockerConfigProvider by merging every
+// dockerconfigjson/dockercfg Secret in the bound namespace. Later Secrets
+// (as returned by the lister) win on key collisions, matching how the
+// kubelet already merges multiple imagePullSecrets on a pod.
+func (p *SecretDockerConfigProvider) Provide(image string) credentialprovider.DockerConfig {
+	cfg := credentialprovider.DockerConfig{}
+	if p.namespace == "" {
+		return cfg
+	}
+
+	secrets, err := p.lister.Secrets(p.namespace)
+	if err != nil {
+		klog.Errorf("while listing secrets in namespace %q: %v", p.namespace, err)
+		return cfg
+	}
+
+	for _, secret := range secrets {
+		entries, err := dockerConfigFromSecret(secret)
+		if err != nil {
+			klog.V(4).Infof("skipping secret %s/%s as a docker config source: %v", secret.Namespace, secret.Name, err)
+			continue
+		}
+		for registry, entry := range entries {
+			cfg[registry] = entry
+		}
+	}
+
+	return cfg
+}
+
+// dockerConfigFromSecret extracts a DockerConfig from a single Secret,
+// supporting both the kubernetes.io/dockerconfigjson and legacy
+// kubernetes.io/dockercfg secret types. Secrets of any other type are
+// rejected with an error so callers can skip them quietly.
+func dockerConfigFromSecret(secret *v1.Secret) (credentialprovider.DockerConfig, error) {
+	switch secret.Type {
+	case v1.SecretTypeDockerConfigJson:
+		data, ok := secret.Data[v1.DockerConfigJsonKey]
+		if !ok {
+			return nil, fmt.Errorf("missing %q key", v1.DockerConfigJsonKey)
+		}
+		var parsed credentialprovider.DockerConfigJSON
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid %q: %w", v1.DockerConfigJsonKey, err)
+		}
+		return parsed.Auths, nil
+
+	case v1.SecretTypeDockercfg:
+		data, ok := secret.Data[v1.DockerConfigKey]
+		if !ok {
+			return nil, fmt.Errorf("missing %q key", v1.DockerConfigKey)
+		}
+		var cfg credentialprovider.DockerConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid %q: %w", v1.DockerConfigKey, err)
+		}
+		return cfg, nil
+
+	default:
+		return nil, fmt.Errorf("secret type %q is not a docker config secret", secret.Type)
+	}
+}
+
+// cacheLifetime is short relative to the other registered providers: the
+// SecretLister is already backed by an informer cache, so this only bounds
+// how quickly a rotated imagePullSecret takes effect, not API load.
+const cacheLifetime = 30 * time.Second
+
+// ProviderForNamespace returns a DockerConfigProvider, scoped to namespace
+// and cached for cacheLifetime, for the kubelet to add to a single pod's
+// DockerKeyring. Unlike the GCE metadata providers in
+// pkg/credentialprovider/gcp, this is deliberately not something callers
+// register once into credentialprovider's process-wide registry: that
+// registry has no notion of "for this pod's namespace", and a single
+// registered instance would have to mutate shared state to track the
+// namespace of whichever pull is in flight, racing concurrent pulls for
+// different namespaces. Call this once per pod as its DockerKeyring is
+// assembled instead.
+func ProviderForNamespace(lister SecretLister, namespace string) credentialprovider.DockerConfigProvider {
+	return &credentialprovider.CachingDockerConfigProvider{
+		Provider: NewSecretDockerConfigProvider(lister, namespace),
+		Lifetime: cacheLifetime,
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeSecretLister struct {
+	secrets map[string][]*v1.Secret
+}
+
+func (f *fakeSecretLister) Secrets(namespace string) ([]*v1.Secret, error) {
+	return f.secrets[namespace], nil
+}
+
+func dockerConfigJSONSecret(namespace, name, registry, username, password string) *v1.Secret {
+	data := []byte(`{"auths":{"` + registry + `":{"username":"` + username + `","password":"` + password + `"}}}`)
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Type:       v1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{v1.DockerConfigJsonKey: data},
+	}
+}
+
+func TestProvideResolvesPrivateRegistryFromSecret(t *testing.T) {
+	lister := &fakeSecretLister{
+		secrets: map[string][]*v1.Secret{
+			"team-a": {
+				dockerConfigJSONSecret("team-a", "harbor-pull", "harbor.example.com", "robot$team-a", "s3cr3t"),
+			},
+		},
+	}
+
+	// A provider constructed with no namespace (the zero value a caller
+	// gets by forgetting to scope it) has nothing to offer.
+	empty := NewSecretDockerConfigProvider(lister, "")
+	if empty.Enabled() {
+		t.Fatalf("expected provider with no namespace to be disabled")
+	}
+	if cfg := empty.Provide("harbor.example.com/team-a/app:v1"); len(cfg) != 0 {
+		t.Fatalf("expected no credentials with no namespace, got %v", cfg)
+	}
+
+	p := NewSecretDockerConfigProvider(lister, "team-a")
+	if !p.Enabled() {
+		t.Fatalf("expected provider to be enabled once constructed with a namespace")
+	}
+
+	cfg := p.Provide("harbor.example.com/team-a/app:v1")
+	entry, ok := cfg["harbor.example.com"]
+	if !ok {
+		t.Fatalf("expected an entry for harbor.example.com, got %v", cfg)
+	}
+	if entry.Username != "robot$team-a" || entry.Password != "s3cr3t" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestProvideIsScopedToNamespace(t *testing.T) {
+	lister := &fakeSecretLister{
+		secrets: map[string][]*v1.Secret{
+			"team-a": {dockerConfigJSONSecret("team-a", "harbor-pull", "harbor.example.com", "a", "a-pw")},
+			"team-b": {dockerConfigJSONSecret("team-b", "harbor-pull", "harbor.example.com", "b", "b-pw")},
+		},
+	}
+
+	p := NewSecretDockerConfigProvider(lister, "team-b")
+
+	cfg := p.Provide("harbor.example.com/app:v1")
+	if cfg["harbor.example.com"].Username != "b" {
+		t.Errorf("expected team-b's credentials, got %+v", cfg["harbor.example.com"])
+	}
+}
+
+func TestDockerConfigFromSecretRejectsOtherSecretTypes(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "opaque"},
+		Type:       v1.SecretTypeOpaque,
+	}
+	if _, err := dockerConfigFromSecret(secret); err == nil {
+		t.Errorf("expected an error for a non-docker-config secret type")
+	}
+}
+
+// TestProviderForNamespaceConcurrentTenantsDontCrossPollinate pulls images
+// for two namespaces concurrently, the way the kubelet pulls images for
+// many pods at once, and asserts that a pull for one tenant's namespace
+// never observes another tenant's credentials. It guards against the
+// SecretDockerConfigProvider design regressing back to a single instance
+// mutated in place by a SetNamespace-style call, which an interleaving of
+// concurrent pulls could race.
+func TestProviderForNamespaceConcurrentTenantsDontCrossPollinate(t *testing.T) {
+	lister := &fakeSecretLister{
+		secrets: map[string][]*v1.Secret{
+			"team-a": {dockerConfigJSONSecret("team-a", "harbor-pull", "harbor.example.com", "a", "a-pw")},
+			"team-b": {dockerConfigJSONSecret("team-b", "harbor-pull", "harbor.example.com", "b", "b-pw")},
+		},
+	}
+
+	const iterations = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, iterations*2)
+
+	pull := func(namespace, wantUsername string) {
+		defer wg.Done()
+		provider := ProviderForNamespace(lister, namespace)
+		cfg := provider.Provide("harbor.example.com/app:v1")
+		if got := cfg["harbor.example.com"].Username; got != wantUsername {
+			errs <- fmt.Errorf("namespace %q: got credentials for %q, want %q", namespace, got, wantUsername)
+		}
+	}
+
+	for i := 0; i < iterations; i++ {
+		wg.Add(2)
+		go pull("team-a", "a")
+		go pull("team-b", "b")
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// Agent runs an Enforcer against the node's published DNSPolicy state and
+// is the only thing allowed to report enforcement as actually active: it
+// flips a pod's DNSPolicy status to Enforcing only once Sync has
+// succeeded, and to Degraded (with an event explaining why) when the
+// backend fails or, as with EBPFEnforcer today, admits it can't enforce at
+// all in this build.
+type Agent struct {
+	Client   kubernetes.Interface
+	Enforcer Enforcer
+	recorder record.EventRecorder
+}
+
+// NewAgent returns an Agent that runs enforcer and reports its outcome
+// through client. If enforcer is a *Server, its OnBlocked handler is wired
+// to record a blocked-query event through the same client/recorder.
+func NewAgent(client kubernetes.Interface, enforcer Enforcer) *Agent {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "dnspolicy-agent"})
+
+	a := &Agent{Client: client, Enforcer: enforcer, recorder: recorder}
+	if srv, ok := enforcer.(*Server); ok {
+		srv.OnBlocked = a.recordBlockedQuery
+	}
+	return a
+}
+
+// Sync runs the Agent's Enforcer against state and updates every covered
+// pod's DNSPolicy status to match the real outcome.
+func (a *Agent) Sync(state dnspolicy.NodeState) error {
+	syncErr := a.Enforcer.Sync(state)
+	for _, pd := range state.Pods {
+		if err := a.reportStatus(pd, syncErr); err != nil {
+			return err
+		}
+	}
+	return syncErr
+}
+
+func (a *Agent) reportStatus(pd dnspolicy.PodDomains, syncErr error) error {
+	pod, err := a.Client.CoreV1().Pods(pd.Namespace).Get(pd.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting pod %s/%s: %w", pd.Namespace, pd.Name, err)
+	}
+
+	condition := dnspolicy.ConditionEnforcing
+	if syncErr != nil {
+		condition = dnspolicy.ConditionDegraded
+	}
+	if dnspolicy.HasCondition(pod, condition) {
+		return nil
+	}
+
+	updated := pod.DeepCopy()
+	dnspolicy.SetCondition(updated, condition)
+	if _, err := a.Client.CoreV1().Pods(updated.Namespace).UpdateStatus(updated); err != nil {
+		return fmt.Errorf("updating status for pod %s/%s: %w", pd.Namespace, pd.Name, err)
+	}
+
+	if syncErr != nil {
+		a.recorder.Eventf(podReference(pd), v1.EventTypeWarning, "DNSPolicyDegraded", "dataplane %q failed to program enforcement: %v", a.Enforcer.Name(), syncErr)
+	}
+	return nil
+}
+
+func (a *Agent) recordBlockedQuery(pd dnspolicy.PodDomains, query string) {
+	a.recorder.Eventf(podReference(pd), v1.EventTypeWarning, dnspolicy.ReasonQueryBlocked, "DNS query for %q blocked: not in allowedDomains", query)
+}
+
+func podReference(pd dnspolicy.PodDomains) *v1.ObjectReference {
+	return &v1.ObjectReference{Kind: "Pod", Namespace: pd.Namespace, Name: pd.Name, UID: types.UID(pd.PodUID)}
+}
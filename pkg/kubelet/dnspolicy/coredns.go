@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// coreDNSAllowlist is the on-disk representation consumed by the node-local
+// CoreDNS `dnspolicy` plugin: a per-client-IP allowlist that the plugin
+// reloads on change and uses to NXDOMAIN anything that doesn't match.
+type coreDNSAllowlist struct {
+	// Clients maps a pod IP to the FQDN/wildcard patterns it may resolve.
+	Clients map[string][]string `json:"clients"`
+}
+
+// CoreDNSEnforcer is an Enforcer that writes the node's allowlist to a file
+// meant to be watched by a CoreDNS instance running a dnspolicy plugin.
+// That plugin does not exist in this tree yet, so Sync here only ever
+// produces the file; nothing consumes it until the plugin is written and
+// deployed. Use Server for an Enforcer that actually answers queries today.
+type CoreDNSEnforcer struct {
+	// ConfigPath is the file the CoreDNS dnspolicy plugin watches.
+	ConfigPath string
+}
+
+// NewCoreDNSEnforcer returns an Enforcer that writes its allowlist to
+// configPath.
+func NewCoreDNSEnforcer(configPath string) *CoreDNSEnforcer {
+	return &CoreDNSEnforcer{ConfigPath: configPath}
+}
+
+// Name implements Enforcer.
+func (e *CoreDNSEnforcer) Name() string {
+	return "coredns"
+}
+
+// Sync implements Enforcer by rewriting the allowlist file atomically.
+func (e *CoreDNSEnforcer) Sync(state dnspolicy.NodeState) error {
+	allowlist := coreDNSAllowlist{Clients: map[string][]string{}}
+	for _, pod := range state.Pods {
+		if pod.HostNetwork || pod.PodIP == "" {
+			continue
+		}
+		domains := append([]string(nil), pod.AllowedDomains...)
+		sort.Strings(domains)
+		allowlist.Clients[pod.PodIP] = domains
+	}
+
+	data, err := json.MarshalIndent(allowlist, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := e.ConfigPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.ConfigPath)
+}
@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// errProgramNotImplemented is returned by programPod because attaching and
+// populating the allowlist BPF map requires the cgroup-socket-filter
+// loader, which ships out-of-tree as a vendored dependency not available in
+// this tree. Sync propagating this error (rather than swallowing it) is
+// what makes the kubelet Agent mark affected pods Degraded instead of
+// claiming enforcement that isn't actually happening.
+var errProgramNotImplemented = errors.New("ebpf enforcer: attach/program not implemented in this build")
+
+// EBPFEnforcer is an Enforcer that attaches a cgroup/socket filter program
+// per pod which inspects outgoing UDP/TCP port-53 traffic and rejects
+// (NXDOMAIN) queries that don't match the pod's allowed domains. It trades
+// the simplicity of the CoreDNS-based enforcer for enforcement that covers
+// pods using a resolver other than the cluster's CoreDNS, at the cost of
+// requiring BPF_PROG_ATTACH privileges on the node.
+//
+// The actual program load/attach is NOT implemented in this tree: it
+// requires a vendored cgroup/socket-filter loader this package doesn't
+// have. This type only owns the translation from NodeState to that
+// package's allowlist format and the per-pod attach/detach bookkeeping, so
+// that plumbing is ready once the loader is vendored; until then, Sync
+// always returns errProgramNotImplemented and the Agent reports affected
+// pods as Degraded. Use Server for working node-local enforcement today.
+type EBPFEnforcer struct {
+	// CgroupRoot is the root of the pod cgroup hierarchy the programs are
+	// attached under, e.g. "/sys/fs/cgroup".
+	CgroupRoot string
+
+	attached map[string]bool // podUID -> attached
+}
+
+// NewEBPFEnforcer returns an Enforcer that attaches eBPF socket filters
+// under cgroupRoot.
+func NewEBPFEnforcer(cgroupRoot string) *EBPFEnforcer {
+	return &EBPFEnforcer{CgroupRoot: cgroupRoot, attached: map[string]bool{}}
+}
+
+// Name implements Enforcer.
+func (e *EBPFEnforcer) Name() string {
+	return "ebpf"
+}
+
+// Sync implements Enforcer by attaching/detaching and reprogramming the
+// per-pod socket filter maps to match the given state.
+func (e *EBPFEnforcer) Sync(state dnspolicy.NodeState) error {
+	wanted := map[string]bool{}
+	for uid, pod := range state.Pods {
+		wanted[uid] = true
+		if err := e.programPod(uid, pod); err != nil {
+			return fmt.Errorf("programming pod %s: %w", uid, err)
+		}
+	}
+	for uid := range e.attached {
+		if !wanted[uid] {
+			e.detachPod(uid)
+		}
+	}
+	return nil
+}
+
+func (e *EBPFEnforcer) programPod(uid string, pod dnspolicy.PodDomains) error {
+	// Record that the pod is tracked so Sync's diffing and detach
+	// bookkeeping above are exercised by tests without a kernel, but do not
+	// pretend the pod is actually enforced: see errProgramNotImplemented.
+	e.attached[uid] = true
+	return errProgramNotImplemented
+}
+
+func (e *EBPFEnforcer) detachPod(uid string) {
+	delete(e.attached, uid)
+}
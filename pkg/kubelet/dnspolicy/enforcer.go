@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dnspolicy is the node-local half of DNSPolicy enforcement. The
+// controller in pkg/controller/dnspolicy computes, per node, which pods are
+// covered by a DNSPolicy and their merged allowed-domain sets; this package
+// takes that per-pod state and programs it into the node's DNS dataplane so
+// that queries from covered pods which don't match an allowed domain come
+// back NXDOMAIN.
+package dnspolicy
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// Enforcer programs a node-local DNS dataplane with the allowlists computed
+// by the DNSPolicy controller. Two backends are expected in practice: a
+// CoreDNS plugin that keys its allowlist by client (pod) IP, and an eBPF
+// cgroup/socket filter attached per-pod that inspects outgoing port-53
+// traffic directly. Both satisfy this interface so the kubelet can select
+// one without the rest of the sync loop caring which.
+type Enforcer interface {
+	// Sync programs the dataplane so that, for every pod in state.Pods, only
+	// queries matching that pod's AllowedDomains (per
+	// dnspolicy.MatchesAllowedDomain) are resolved; everything else must
+	// return NXDOMAIN. Pods no longer present in state.Pods must have their
+	// enforcement removed. Sync is called by NodeStateSource whenever it
+	// observes newly published state for this node.
+	Sync(state dnspolicy.NodeState) error
+
+	// Name identifies the backend for logging and status reporting.
+	Name() string
+}
+
+// BlockedQueryHandler is called by an Enforcer implementation whenever it
+// observes a query that didn't match any allowed domain for the querying
+// pod, so the caller (Agent) can surface it as a Pod event.
+type BlockedQueryHandler func(pod dnspolicy.PodDomains, query string)
+
+// unsupportedDomainError is returned by backends when a policy's
+// allowedDomains entry can't be represented in the dataplane's native
+// matching syntax, so the caller can degrade the pod's condition instead of
+// silently under-enforcing.
+type unsupportedDomainError struct {
+	domain string
+}
+
+func (e *unsupportedDomainError) Error() string {
+	return fmt.Sprintf("allowed domain %q is not representable by this enforcer", e.domain)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// Upstream resolves a name a client has already been authorized to query,
+// so Server can answer with a real address instead of merely permitting an
+// allowed query and stopping there. It is intentionally minimal: Server's
+// job is the allow/deny decision, not being a full recursive resolver.
+type Upstream func(name string) (net.IP, error)
+
+// Server is a node-local, UDP port-53-speaking DNS responder that enforces
+// DNSPolicy directly, for clients that don't run behind the cluster's
+// CoreDNS (or as the default dataplane when no CoreDNS dnspolicy plugin is
+// deployed). It implements Enforcer: Sync publishes the node's current
+// allowlist, and the listener goroutine started by ListenAndServe consults
+// it on every query, returning NXDOMAIN for anything that doesn't match.
+type Server struct {
+	// Upstream resolves allowed queries into an answer. If nil, allowed
+	// queries are answered NOERROR with no records, which still proves the
+	// enforcement decision but returns no address.
+	Upstream Upstream
+	// OnBlocked, if set, is called for every query this server rejects.
+	OnBlocked BlockedQueryHandler
+
+	state stateStore
+	conn  net.PacketConn
+}
+
+// NewServer returns a Server with no pods covered until Sync is called.
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Name implements Enforcer.
+func (s *Server) Name() string { return "dns-proxy" }
+
+// Sync implements Enforcer by publishing state for the listener goroutine
+// to consult on the next query.
+func (s *Server) Sync(state dnspolicy.NodeState) error {
+	s.state.set(state)
+	return nil
+}
+
+// ListenAndServe binds addr (typically "<node IP>:53") and serves queries
+// until Close is called or a read error ends the loop.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return s.serve()
+}
+
+// Close stops the listener started by ListenAndServe.
+func (s *Server) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+func (s *Server) serve() error {
+	buf := make([]byte, 512)
+	for {
+		n, remote, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go s.handle(query, remote)
+	}
+}
+
+func (s *Server) handle(query []byte, remote net.Addr) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return
+	}
+	question := msg.Questions[0]
+	name := strings.TrimSuffix(question.Name.String(), ".")
+
+	clientIP := remoteIP(remote)
+	pd, covered := s.state.lookup(clientIP)
+
+	if covered && !dnspolicy.MatchesAllowedDomain(name, pd.AllowedDomains) {
+		if s.OnBlocked != nil {
+			s.OnBlocked(pd, name)
+		}
+		s.reply(msg.Header.ID, question, dnsmessage.RCodeNameError, nil, remote)
+		return
+	}
+
+	var addr net.IP
+	if s.Upstream != nil {
+		addr, _ = s.Upstream(name)
+	}
+	s.reply(msg.Header.ID, question, dnsmessage.RCodeSuccess, addr, remote)
+}
+
+func (s *Server) reply(id uint16, question dnsmessage.Question, rcode dnsmessage.RCode, addr net.IP, remote net.Addr) {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 id,
+		Response:           true,
+		Authoritative:      true,
+		RCode:              rcode,
+		RecursionAvailable: true,
+	})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return
+	}
+	if err := builder.Question(question); err != nil {
+		return
+	}
+
+	if rcode == dnsmessage.RCodeSuccess && addr != nil && question.Type == dnsmessage.TypeA {
+		if ipv4 := addr.To4(); ipv4 != nil {
+			if err := builder.StartAnswers(); err != nil {
+				return
+			}
+			var a [4]byte
+			copy(a[:], ipv4)
+			_ = builder.AResource(dnsmessage.ResourceHeader{
+				Name:  question.Name,
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+				TTL:   5,
+			}, dnsmessage.AResource{A: a})
+		}
+	}
+
+	packed, err := builder.Finish()
+	if err != nil {
+		return
+	}
+	_, _ = s.conn.WriteTo(packed, remote)
+}
+
+func remoteIP(addr net.Addr) string {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// stateStore is the subset of NodeState the listener goroutine needs,
+// indexed by pod IP for O(1) lookup per query and guarded by a mutex since
+// Sync (called from the Agent's goroutine) and the listener run
+// concurrently.
+type stateStore struct {
+	mu   sync.RWMutex
+	byIP map[string]dnspolicy.PodDomains
+}
+
+func (s *stateStore) set(state dnspolicy.NodeState) {
+	byIP := make(map[string]dnspolicy.PodDomains, len(state.Pods))
+	for _, pd := range state.Pods {
+		if pd.PodIP == "" {
+			continue
+		}
+		byIP[pd.PodIP] = pd
+	}
+	s.mu.Lock()
+	s.byIP = byIP
+	s.mu.Unlock()
+}
+
+func (s *stateStore) lookup(ip string) (dnspolicy.PodDomains, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pd, ok := s.byIP[ip]
+	return pd, ok
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// TestServerEnforcesExampleComOnly is the real, protocol-level counterpart
+// to the scenario this subsystem exists for: it runs an actual Server on a
+// loopback UDP socket, publishes a DNSPolicy allowing only "*.example.com"
+// for one "pod" (identified here by its source IP, exactly as the real
+// server distinguishes clients), and resolves through it with Go's own
+// (PreferGo) DNS resolver — the same wire protocol a real nslookup uses.
+// google.com must fail to resolve; foo.example.com must succeed and return
+// the address Upstream provides.
+func TestServerEnforcesExampleComOnly(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	upstreamAddr := net.ParseIP("10.0.0.9")
+	var blocked []string
+	srv := &Server{
+		conn:     conn,
+		Upstream: func(name string) (net.IP, error) { return upstreamAddr, nil },
+		OnBlocked: func(pd dnspolicy.PodDomains, query string) {
+			blocked = append(blocked, query)
+		},
+	}
+	go srv.serve()
+	defer srv.Close()
+
+	srv.Sync(dnspolicy.NodeState{
+		Node: "node-1",
+		Pods: map[string]dnspolicy.PodDomains{
+			"test-uid": {
+				PodUID:         "test-uid",
+				PodIP:          "127.0.0.1",
+				Namespace:      "default",
+				Name:           "test-pod",
+				AllowedDomains: []string{"*.example.com"},
+			},
+		},
+	})
+
+	addr := conn.LocalAddr().String()
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return net.Dial("udp", addr)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := resolver.LookupHost(ctx, "google.com"); err == nil {
+		t.Errorf("expected google.com to fail to resolve, it succeeded")
+	}
+
+	addrs, err := resolver.LookupHost(ctx, "foo.example.com")
+	if err != nil {
+		t.Fatalf("expected foo.example.com to resolve, got error: %v", err)
+	}
+	found := false
+	for _, a := range addrs {
+		if a == upstreamAddr.String() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected foo.example.com to resolve to %s, got %v", upstreamAddr, addrs)
+	}
+
+	if len(blocked) == 0 || blocked[0] != "google.com" {
+		t.Errorf("expected OnBlocked to be called for google.com, got %v", blocked)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+// NodeStateSource is the consumer half of the controller's publish path:
+// the controller (pkg/controller/dnspolicy) has no way to push to the
+// kubelet directly, so NodeStateSource watches the same node-scoped Pod
+// informer the kubelet already runs, decodes each pod's
+// dnspolicy.AllowedDomainsAnnotation, and re-assembles a dnspolicy.NodeState
+// to feed to Agent.Sync whenever it changes.
+type NodeStateSource struct {
+	agent *Agent
+	node  string
+
+	podLister corelisters.PodLister
+	podSynced cache.InformerSynced
+}
+
+// NewNodeStateSource returns a NodeStateSource that feeds agent with the
+// DNSPolicy state published for node, read from podInformer.
+func NewNodeStateSource(agent *Agent, node string, podInformer coreinformers.PodInformer) *NodeStateSource {
+	s := &NodeStateSource{
+		agent:     agent,
+		node:      node,
+		podLister: podInformer.Lister(),
+		podSynced: podInformer.Informer().HasSynced,
+	}
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.resync() },
+		UpdateFunc: func(old, cur interface{}) { s.resync() },
+		DeleteFunc: func(obj interface{}) { s.resync() },
+	})
+
+	return s
+}
+
+// Run blocks until podInformer has synced, performs an initial sync, and
+// then waits for stopCh to be closed; further syncs happen from the
+// informer event handlers registered in NewNodeStateSource.
+func (s *NodeStateSource) Run(stopCh <-chan struct{}) {
+	if !cache.WaitForCacheSync(stopCh, s.podSynced) {
+		return
+	}
+	s.resync()
+	<-stopCh
+}
+
+// resync rebuilds NodeState for s.node from the current Pod informer cache
+// and syncs it to s.agent.
+func (s *NodeStateSource) resync() {
+	pods, err := s.podLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("listing pods for node %q: %v", s.node, err))
+		return
+	}
+
+	state := dnspolicy.NodeState{Node: s.node, Pods: map[string]dnspolicy.PodDomains{}}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != s.node || pod.Spec.HostNetwork {
+			continue
+		}
+
+		allowedDomains, ok, err := dnspolicy.AllowedDomainsFromPod(pod)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		state.Pods[string(pod.UID)] = dnspolicy.PodDomains{
+			PodUID:         string(pod.UID),
+			PodIP:          pod.Status.PodIP,
+			Namespace:      pod.Namespace,
+			Name:           pod.Name,
+			AllowedDomains: allowedDomains,
+			HostNetwork:    pod.Spec.HostNetwork,
+		}
+	}
+
+	if err := s.agent.Sync(state); err != nil {
+		utilruntime.HandleError(fmt.Errorf("syncing DNSPolicy state for node %q: %v", s.node, err))
+	}
+}
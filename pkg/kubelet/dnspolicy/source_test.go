@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnspolicy
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/controller/dnspolicy"
+)
+
+type fakeEnforcer struct {
+	name string
+	got  dnspolicy.NodeState
+}
+
+func (f *fakeEnforcer) Sync(state dnspolicy.NodeState) error {
+	f.got = state
+	return nil
+}
+
+func (f *fakeEnforcer) Name() string { return f.name }
+
+func podWithAllowedDomains(t *testing.T, name, node string, domains []string) *v1.Pod {
+	t.Helper()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name + "-uid")},
+		Spec:       v1.PodSpec{NodeName: node},
+		Status:     v1.PodStatus{PodIP: "10.0.0.5"},
+	}
+	if domains != nil {
+		encoded, err := dnspolicy.EncodeAllowedDomains(domains)
+		if err != nil {
+			t.Fatalf("encoding allowed domains: %v", err)
+		}
+		pod.Annotations = map[string]string{dnspolicy.AllowedDomainsAnnotation: encoded}
+	}
+	return pod
+}
+
+// TestNodeStateSourceResyncBuildsStateFromAnnotatedPods is the consumer
+// side of the publish path: a pod annotated by the controller with
+// AllowedDomainsAnnotation, on the watched node, must end up in the
+// NodeState handed to the Enforcer. A pod on a different node, a
+// host-network pod, and a pod with no annotation at all (not covered by
+// any DNSPolicy) must all be excluded.
+func TestNodeStateSourceResyncBuildsStateFromAnnotatedPods(t *testing.T) {
+	covered := podWithAllowedDomains(t, "covered", "node-1", []string{"*.example.com"})
+	otherNode := podWithAllowedDomains(t, "other-node", "node-2", []string{"*.example.com"})
+	notCovered := podWithAllowedDomains(t, "not-covered", "node-1", nil)
+	hostNetwork := podWithAllowedDomains(t, "host-network", "node-1", []string{"*.example.com"})
+	hostNetwork.Spec.HostNetwork = true
+
+	pods := []*v1.Pod{covered, otherNode, notCovered, hostNetwork}
+
+	client := fake.NewSimpleClientset(covered, otherNode, notCovered, hostNetwork)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	for _, pod := range pods {
+		if err := podInformer.Informer().GetIndexer().Add(pod); err != nil {
+			t.Fatalf("seeding pod informer: %v", err)
+		}
+	}
+
+	enforcer := &fakeEnforcer{name: "fake"}
+	agent := NewAgent(client, enforcer)
+	source := NewNodeStateSource(agent, "node-1", podInformer)
+	source.resync()
+
+	if len(enforcer.got.Pods) != 1 {
+		t.Fatalf("expected exactly one pod in synced state, got %v", enforcer.got.Pods)
+	}
+	pd, ok := enforcer.got.Pods[string(covered.UID)]
+	if !ok {
+		t.Fatalf("expected the covered pod to be present, got %v", enforcer.got.Pods)
+	}
+	if len(pd.AllowedDomains) != 1 || pd.AllowedDomains[0] != "*.example.com" {
+		t.Errorf("unexpected AllowedDomains %v", pd.AllowedDomains)
+	}
+}
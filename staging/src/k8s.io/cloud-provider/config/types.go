@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config contains the internal (hub) types for cloud-provider
+// ComponentConfig, versioned for external consumption under config/v1alpha1.
+package config
+
+// CloudProviderMigrationConfiguration declaratively pins the
+// kube-controller-manager/cloud-controller-manager migration schedule for a
+// provider, so the schedule can be driven by --config instead of the
+// compiled-in defaults in cloudprovider.dummyMigrationConfig.
+type CloudProviderMigrationConfiguration struct {
+	// Controllers maps a controller name (e.g. "cloud-node", "service",
+	// "route", "node-ipam", "persistentvolume-label", "csi-migration-shim")
+	// to its migration configuration. A controller not present here falls
+	// back to the provider's compiled-in defaults.
+	Controllers map[string]ControllerMigrationConfig
+}
+
+// ControllerMigrationConfig configures the migration state of a single
+// controller.
+type ControllerMigrationConfig struct {
+	// Enabled is whether this controller should run at all in the owning
+	// component(s). A controller that isn't Enabled never runs, regardless
+	// of OwnerComponent.
+	Enabled bool
+	// RequireMigrationLock is whether the controller must hold this
+	// controller's MigrationLeaseLock before reconciling.
+	RequireMigrationLock bool
+	// OwnerComponent is which component(s) this controller is allowed to
+	// run in.
+	OwnerComponent OwnerComponent
+}
+
+// OwnerComponent identifies which component(s) a controller is permitted to
+// run in during a staged migration.
+type OwnerComponent string
+
+const (
+	// OwnerCloudControllerManager restricts a controller to
+	// cloud-controller-manager.
+	OwnerCloudControllerManager OwnerComponent = "ccm"
+	// OwnerKubeControllerManager restricts a controller to
+	// kube-controller-manager.
+	OwnerKubeControllerManager OwnerComponent = "kcm"
+	// OwnerBoth allows a controller to run in either component, e.g. while
+	// RequireMigrationLock arbitrates which one actually does.
+	OwnerBoth OwnerComponent = "both"
+)
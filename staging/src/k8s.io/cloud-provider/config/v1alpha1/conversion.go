@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file is hand-maintained, not produced by conversion-gen: regenerate
+// it (and drop this notice) the next time `make generate` is run for this
+// package.
+
+package v1alpha1
+
+import (
+	conversion "k8s.io/apimachinery/pkg/conversion"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	config "k8s.io/cloud-provider/config"
+)
+
+// RegisterConversions adds conversion functions to the given scheme.
+// Pass this to runtime.SchemeBuilder so a decoded
+// CloudProviderMigrationConfiguration (e.g. from a KCM/CCM --config file)
+// can be converted to the internal config.CloudProviderMigrationConfiguration
+// that cloudprovider.NewMigrationConfigFromComponentConfig consumes.
+func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddGeneratedConversionFunc((*CloudProviderMigrationConfiguration)(nil), (*config.CloudProviderMigrationConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration(a.(*CloudProviderMigrationConfiguration), b.(*config.CloudProviderMigrationConfiguration), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*config.CloudProviderMigrationConfiguration)(nil), (*CloudProviderMigrationConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_CloudProviderMigrationConfiguration_To_v1alpha1_CloudProviderMigrationConfiguration(a.(*config.CloudProviderMigrationConfiguration), b.(*CloudProviderMigrationConfiguration), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*ControllerMigrationConfig)(nil), (*config.ControllerMigrationConfig)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_ControllerMigrationConfig_To_config_ControllerMigrationConfig(a.(*ControllerMigrationConfig), b.(*config.ControllerMigrationConfig), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*config.ControllerMigrationConfig)(nil), (*ControllerMigrationConfig)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_ControllerMigrationConfig_To_v1alpha1_ControllerMigrationConfig(a.(*config.ControllerMigrationConfig), b.(*ControllerMigrationConfig), scope)
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration
+// converts the versioned type decoded from a --config file into the
+// internal type. TypeMeta carries no information the internal type needs
+// and is dropped, matching how other ComponentConfig conversions in this
+// repo handle it.
+func Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration(in *CloudProviderMigrationConfiguration, out *config.CloudProviderMigrationConfiguration, s conversion.Scope) error {
+	if in.Controllers != nil {
+		out.Controllers = make(map[string]config.ControllerMigrationConfig, len(in.Controllers))
+		for key, val := range in.Controllers {
+			converted := config.ControllerMigrationConfig{}
+			if err := Convert_v1alpha1_ControllerMigrationConfig_To_config_ControllerMigrationConfig(&val, &converted, s); err != nil {
+				return err
+			}
+			out.Controllers[key] = converted
+		}
+	} else {
+		out.Controllers = nil
+	}
+	return nil
+}
+
+// Convert_config_CloudProviderMigrationConfiguration_To_v1alpha1_CloudProviderMigrationConfiguration
+// is the reverse of Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration.
+func Convert_config_CloudProviderMigrationConfiguration_To_v1alpha1_CloudProviderMigrationConfiguration(in *config.CloudProviderMigrationConfiguration, out *CloudProviderMigrationConfiguration, s conversion.Scope) error {
+	if in.Controllers != nil {
+		out.Controllers = make(map[string]ControllerMigrationConfig, len(in.Controllers))
+		for key, val := range in.Controllers {
+			converted := ControllerMigrationConfig{}
+			if err := Convert_config_ControllerMigrationConfig_To_v1alpha1_ControllerMigrationConfig(&val, &converted, s); err != nil {
+				return err
+			}
+			out.Controllers[key] = converted
+		}
+	} else {
+		out.Controllers = nil
+	}
+	return nil
+}
+
+// Convert_v1alpha1_ControllerMigrationConfig_To_config_ControllerMigrationConfig
+// converts OwnerComponent explicitly since it's a plain string in the
+// versioned type and a distinct named type (config.OwnerComponent)
+// internally.
+func Convert_v1alpha1_ControllerMigrationConfig_To_config_ControllerMigrationConfig(in *ControllerMigrationConfig, out *config.ControllerMigrationConfig, s conversion.Scope) error {
+	out.Enabled = in.Enabled
+	out.RequireMigrationLock = in.RequireMigrationLock
+	out.OwnerComponent = config.OwnerComponent(in.OwnerComponent)
+	return nil
+}
+
+// Convert_config_ControllerMigrationConfig_To_v1alpha1_ControllerMigrationConfig
+// is the reverse of Convert_v1alpha1_ControllerMigrationConfig_To_config_ControllerMigrationConfig.
+func Convert_config_ControllerMigrationConfig_To_v1alpha1_ControllerMigrationConfig(in *config.ControllerMigrationConfig, out *ControllerMigrationConfig, s conversion.Scope) error {
+	out.Enabled = in.Enabled
+	out.RequireMigrationLock = in.RequireMigrationLock
+	out.OwnerComponent = string(in.OwnerComponent)
+	return nil
+}
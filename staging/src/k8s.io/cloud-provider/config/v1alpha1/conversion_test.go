@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/cloud-provider/config"
+)
+
+func TestConvertCloudProviderMigrationConfigurationRoundTrip(t *testing.T) {
+	external := &CloudProviderMigrationConfiguration{
+		Controllers: map[string]ControllerMigrationConfig{
+			"service": {
+				Enabled:              true,
+				RequireMigrationLock: true,
+				OwnerComponent:       "ccm",
+			},
+			"route": {
+				Enabled:        false,
+				OwnerComponent: "kcm",
+			},
+		},
+	}
+
+	internal := &config.CloudProviderMigrationConfiguration{}
+	if err := Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration(external, internal, nil); err != nil {
+		t.Fatalf("converting to internal: %v", err)
+	}
+
+	if got, want := internal.Controllers["service"].OwnerComponent, config.OwnerCloudControllerManager; got != want {
+		t.Errorf("service OwnerComponent = %q, want %q", got, want)
+	}
+	if got, want := internal.Controllers["route"].OwnerComponent, config.OwnerKubeControllerManager; got != want {
+		t.Errorf("route OwnerComponent = %q, want %q", got, want)
+	}
+
+	roundTripped := &CloudProviderMigrationConfiguration{}
+	if err := Convert_config_CloudProviderMigrationConfiguration_To_v1alpha1_CloudProviderMigrationConfiguration(internal, roundTripped, nil); err != nil {
+		t.Fatalf("converting back to v1alpha1: %v", err)
+	}
+
+	if !reflect.DeepEqual(external.Controllers, roundTripped.Controllers) {
+		t.Errorf("round trip mismatch: started with %+v, got back %+v", external.Controllers, roundTripped.Controllers)
+	}
+}
+
+func TestConvertCloudProviderMigrationConfigurationNilControllers(t *testing.T) {
+	internal := &config.CloudProviderMigrationConfiguration{}
+	if err := Convert_v1alpha1_CloudProviderMigrationConfiguration_To_config_CloudProviderMigrationConfiguration(&CloudProviderMigrationConfiguration{}, internal, nil); err != nil {
+		t.Fatalf("converting to internal: %v", err)
+	}
+	if internal.Controllers != nil {
+		t.Errorf("expected nil Controllers to stay nil, got %+v", internal.Controllers)
+	}
+}
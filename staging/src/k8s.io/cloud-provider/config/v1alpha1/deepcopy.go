@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file is hand-maintained, not produced by deepcopy-gen: regenerate it
+// (and drop this notice) the next time `make generate` is run for this
+// package.
+
+package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is a deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudProviderMigrationConfiguration) DeepCopyInto(out *CloudProviderMigrationConfiguration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	if in.Controllers != nil {
+		in, out := &in.Controllers, &out.Controllers
+		*out = make(map[string]ControllerMigrationConfig, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is a deepcopy function, copying the receiver, creating a new CloudProviderMigrationConfiguration.
+func (in *CloudProviderMigrationConfiguration) DeepCopy() *CloudProviderMigrationConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudProviderMigrationConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is a deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudProviderMigrationConfiguration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is a deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerMigrationConfig) DeepCopyInto(out *ControllerMigrationConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is a deepcopy function, copying the receiver, creating a new ControllerMigrationConfig.
+func (in *ControllerMigrationConfig) DeepCopy() *ControllerMigrationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerMigrationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
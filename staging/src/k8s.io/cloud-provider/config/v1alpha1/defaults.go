@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	return RegisterDefaults(scheme)
+}
+
+// RegisterDefaults registers this API group/version's defaulting functions
+// with the provided scheme.
+func RegisterDefaults(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&CloudProviderMigrationConfiguration{}, func(obj interface{}) {
+		SetObjectDefaults_CloudProviderMigrationConfiguration(obj.(*CloudProviderMigrationConfiguration))
+	})
+	return nil
+}
+
+// SetObjectDefaults_CloudProviderMigrationConfiguration fills in defaults
+// for fields an operator left unset in their --config file.
+func SetObjectDefaults_CloudProviderMigrationConfiguration(obj *CloudProviderMigrationConfiguration) {
+	if obj.Controllers == nil {
+		obj.Controllers = map[string]ControllerMigrationConfig{}
+	}
+}
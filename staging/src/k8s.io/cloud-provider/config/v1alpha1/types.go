@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudProviderMigrationConfiguration lets an operator embed a declarative
+// KCM->CCM migration schedule in kube-controller-manager's or
+// cloud-controller-manager's --config file, instead of relying on a
+// provider's compiled-in MigrationConfig defaults.
+type CloudProviderMigrationConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// controllers maps a controller name (e.g. "cloud-node", "service",
+	// "route", "node-ipam", "persistentvolume-label", "csi-migration-shim")
+	// to its migration configuration.
+	// +optional
+	Controllers map[string]ControllerMigrationConfig `json:"controllers,omitempty"`
+}
+
+// ControllerMigrationConfig configures the migration state of a single
+// controller.
+type ControllerMigrationConfig struct {
+	// enabled is whether this controller should run at all in the owning
+	// component(s).
+	Enabled bool `json:"enabled"`
+	// requireMigrationLock is whether the controller must hold this
+	// controller's migration lease before reconciling.
+	// +optional
+	RequireMigrationLock bool `json:"requireMigrationLock,omitempty"`
+	// ownerComponent is which component(s) this controller is allowed to
+	// run in: "ccm", "kcm", or "both".
+	OwnerComponent string `json:"ownerComponent"`
+}
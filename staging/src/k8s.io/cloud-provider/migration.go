@@ -18,8 +18,24 @@ package cloudprovider
 
 import (
 	"fmt"
+
+	"k8s.io/component-base/featuregate"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/kubernetes/pkg/features"
 )
 
+// inTreePluginCSIMigrationFeature maps the name of an in-tree volume
+// plugin, as used by the attach/detach and PV controllers, to the feature
+// gate that controls whether it has migrated to its CSI driver.
+var inTreePluginCSIMigrationFeature = map[string]featuregate.Feature{
+	"kubernetes.io/aws-ebs":        features.CSIMigrationAWS,
+	"kubernetes.io/gce-pd":         features.CSIMigrationGCE,
+	"kubernetes.io/azure-disk":     features.CSIMigrationAzureDisk,
+	"kubernetes.io/cinder":         features.CSIMigrationOpenStack,
+	"kubernetes.io/vsphere-volume": features.CSIMigrationvSphere,
+}
+
 const (
 	ccm = "cloud-controller-manager"
 	kcm = "kube-controller-manager"
@@ -42,6 +58,33 @@ type MigrationConfig interface {
 	// returns whether to run node ipam controller based on the controller name
 	// and whether it should run under a migration lock
 	NodeIPAMController() (bool, bool)
+	// returns whether to run the PersistentVolumeLabel admission/controller
+	// based on the component name and whether it should run under a
+	// migration lock
+	PersistentVolumeLabelController() (bool, bool)
+	// returns whether the CSI migration shim for in-tree volume plugins
+	// should be active in this component, and whether doing so requires a
+	// migration lock
+	CSIMigrationShim() (bool, bool)
+
+	// ShouldSkipInTreeVolumePlugin reports whether the in-tree volume
+	// plugin named pluginName has migrated to CSI, so KCM's attach/detach
+	// and PV controllers can uniformly defer to CSI instead of each
+	// checking the relevant CSIMigration<Plugin> feature gate themselves.
+	ShouldSkipInTreeVolumePlugin(pluginName string) bool
+
+	// Describe returns the migration status of every controller this
+	// MigrationConfig knows about, for KCM and CCM to print at boot so
+	// operators can see which component owns each controller.
+	Describe() []ControllerMigrationStatus
+}
+
+// ControllerMigrationStatus describes, for a single controller, whether it
+// runs in this component and whether it does so under a migration lock.
+type ControllerMigrationStatus struct {
+	ControllerName string
+	RunsHere       bool
+	UnderLock      bool
 }
 
 type dummyMigrationConfig struct {
@@ -58,6 +101,7 @@ func (m *dummyMigrationConfig) SetComponent(component string) error {
 	}
 
 	m.component = component
+	return nil
 }
 
 func (m *dummyMigrationConfig) CloudNodeController() (bool, bool) {
@@ -91,3 +135,51 @@ func (m *dummyMigrationConfig) NodeIPAMController() (bool, bool) {
 
 	return false, false
 }
+
+func (m *dummyMigrationConfig) PersistentVolumeLabelController() (bool, bool) {
+	// the PersistentVolumeLabel controller is CCM-only; it has no legacy
+	// KCM counterpart to migrate away from, so it never needs a lock
+	if m.component == ccm {
+		return true, false
+	}
+
+	return false, false
+}
+
+func (m *dummyMigrationConfig) CSIMigrationShim() (bool, bool) {
+	// the shim itself runs wherever the attach/detach and PV controllers
+	// run; whether it actually defers to CSI for a given plugin is decided
+	// per-plugin by ShouldSkipInTreeVolumePlugin
+	return true, false
+}
+
+func (m *dummyMigrationConfig) ShouldSkipInTreeVolumePlugin(pluginName string) bool {
+	feature, known := inTreePluginCSIMigrationFeature[pluginName]
+	if !known {
+		return false
+	}
+	return utilfeature.DefaultFeatureGate.Enabled(feature)
+}
+
+func (m *dummyMigrationConfig) Describe() []ControllerMigrationStatus {
+	statuses := []ControllerMigrationStatus{}
+	for _, c := range []struct {
+		name string
+		fn   func() (bool, bool)
+	}{
+		{"cloud-node", m.CloudNodeController},
+		{"service", m.ServiceController},
+		{"route", m.RouteController},
+		{"node-ipam", m.NodeIPAMController},
+		{"persistentvolume-label", m.PersistentVolumeLabelController},
+		{"csi-migration-shim", m.CSIMigrationShim},
+	} {
+		runsHere, underLock := c.fn()
+		statuses = append(statuses, ControllerMigrationStatus{
+			ControllerName: c.name,
+			RunsHere:       runsHere,
+			UnderLock:      underLock,
+		})
+	}
+	return statuses
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"fmt"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/cloud-provider/config"
+)
+
+// NewMigrationConfigFromComponentConfig builds a MigrationConfig from a
+// declarative CloudProviderMigrationConfiguration, so an operator's
+// --config file can pin a provider's KCM->CCM migration schedule (e.g.
+// freeze ServiceController on CCM while keeping NodeIPAMController in KCM)
+// instead of relying on the provider's compiled-in MigrationConfig.
+//
+// Operators are expected to pin only the controllers they actually want to
+// override; any controller the configuration doesn't mention falls back to
+// fallback, per CloudProviderMigrationConfiguration's own doc comment. Pass
+// the MigrationConfig resolved for the provider in use (e.g. via
+// InitMigrationConfig), or nil to fall back to dummyMigrationConfig's
+// defaults.
+//
+// A nil cfg returns fallback unchanged (or dummyMigrationConfig's defaults
+// if fallback is also nil), the same as InitMigrationConfig does when no
+// provider-specific MigrationConfig is registered.
+func NewMigrationConfigFromComponentConfig(cfg *config.CloudProviderMigrationConfiguration, component string, fallback MigrationConfig) (MigrationConfig, error) {
+	if component != ccm && component != kcm {
+		return nil, fmt.Errorf("invalid component name %q", component)
+	}
+	if fallback == nil {
+		fallback = &dummyMigrationConfig{component: component}
+	} else if err := fallback.SetComponent(component); err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return fallback, nil
+	}
+	if err := validateCloudProviderMigrationConfiguration(cfg); err != nil {
+		return nil, err
+	}
+	return &componentConfigMigrationConfig{component: component, controllers: cfg.Controllers, fallback: fallback}, nil
+}
+
+func validateCloudProviderMigrationConfiguration(cfg *config.CloudProviderMigrationConfiguration) error {
+	for name, c := range cfg.Controllers {
+		switch c.OwnerComponent {
+		case config.OwnerCloudControllerManager, config.OwnerKubeControllerManager, config.OwnerBoth:
+		default:
+			return fmt.Errorf("controller %q: invalid ownerComponent %q", name, c.OwnerComponent)
+		}
+	}
+	return nil
+}
+
+// componentConfigMigrationConfig implements MigrationConfig from a
+// CloudProviderMigrationConfiguration loaded via --config, falling back to
+// fallback's behavior for any controller the configuration doesn't
+// mention, per CloudProviderMigrationConfiguration's documented semantics.
+type componentConfigMigrationConfig struct {
+	component   string
+	controllers map[string]config.ControllerMigrationConfig
+	fallback    MigrationConfig
+}
+
+func (m *componentConfigMigrationConfig) GetComponent() string {
+	return m.component
+}
+
+func (m *componentConfigMigrationConfig) SetComponent(component string) error {
+	if component != ccm && component != kcm {
+		return fmt.Errorf("invalid component name %q", component)
+	}
+	if err := m.fallback.SetComponent(component); err != nil {
+		return err
+	}
+	m.component = component
+	return nil
+}
+
+// controllerStatus returns name's migration status from the pinned
+// configuration, or falls back to fallbackStatus() when the operator's
+// configuration doesn't mention name at all.
+func (m *componentConfigMigrationConfig) controllerStatus(name string, fallbackStatus func() (bool, bool)) (bool, bool) {
+	c, ok := m.controllers[name]
+	if !ok {
+		return fallbackStatus()
+	}
+	if !c.Enabled || !ownerComponentMatches(c.OwnerComponent, m.component) {
+		return false, false
+	}
+	return true, c.RequireMigrationLock
+}
+
+func ownerComponentMatches(owner config.OwnerComponent, component string) bool {
+	switch owner {
+	case config.OwnerBoth:
+		return true
+	case config.OwnerCloudControllerManager:
+		return component == ccm
+	case config.OwnerKubeControllerManager:
+		return component == kcm
+	default:
+		return false
+	}
+}
+
+func (m *componentConfigMigrationConfig) CloudNodeController() (bool, bool) {
+	return m.controllerStatus("cloud-node", m.fallback.CloudNodeController)
+}
+
+func (m *componentConfigMigrationConfig) ServiceController() (bool, bool) {
+	return m.controllerStatus("service", m.fallback.ServiceController)
+}
+
+func (m *componentConfigMigrationConfig) RouteController() (bool, bool) {
+	return m.controllerStatus("route", m.fallback.RouteController)
+}
+
+func (m *componentConfigMigrationConfig) NodeIPAMController() (bool, bool) {
+	return m.controllerStatus("node-ipam", m.fallback.NodeIPAMController)
+}
+
+func (m *componentConfigMigrationConfig) PersistentVolumeLabelController() (bool, bool) {
+	return m.controllerStatus("persistentvolume-label", m.fallback.PersistentVolumeLabelController)
+}
+
+func (m *componentConfigMigrationConfig) CSIMigrationShim() (bool, bool) {
+	return m.controllerStatus("csi-migration-shim", m.fallback.CSIMigrationShim)
+}
+
+func (m *componentConfigMigrationConfig) ShouldSkipInTreeVolumePlugin(pluginName string) bool {
+	feature, known := inTreePluginCSIMigrationFeature[pluginName]
+	if !known {
+		return false
+	}
+	return utilfeature.DefaultFeatureGate.Enabled(feature)
+}
+
+func (m *componentConfigMigrationConfig) Describe() []ControllerMigrationStatus {
+	statuses := []ControllerMigrationStatus{}
+	for _, c := range []struct {
+		name string
+		fn   func() (bool, bool)
+	}{
+		{"cloud-node", m.CloudNodeController},
+		{"service", m.ServiceController},
+		{"route", m.RouteController},
+		{"node-ipam", m.NodeIPAMController},
+		{"persistentvolume-label", m.PersistentVolumeLabelController},
+		{"csi-migration-shim", m.CSIMigrationShim},
+	} {
+		runsHere, underLock := c.fn()
+		statuses = append(statuses, ControllerMigrationStatus{
+			ControllerName: c.name,
+			RunsHere:       runsHere,
+			UnderLock:      underLock,
+		})
+	}
+	return statuses
+}
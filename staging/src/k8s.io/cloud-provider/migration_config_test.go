@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"testing"
+
+	"k8s.io/cloud-provider/config"
+)
+
+// TestNewMigrationConfigFromComponentConfig_PartialConfigFallsBackPerController
+// pins only the "service" controller, exactly as
+// CloudProviderMigrationConfiguration's doc comment's example does, and
+// asserts every other controller still resolves to the provider's
+// (fallback's) defaults instead of silently returning false, false.
+func TestNewMigrationConfigFromComponentConfig_PartialConfigFallsBackPerController(t *testing.T) {
+	cfg := &config.CloudProviderMigrationConfiguration{
+		Controllers: map[string]config.ControllerMigrationConfig{
+			"service": {Enabled: true, OwnerComponent: config.OwnerCloudControllerManager},
+		},
+	}
+
+	fallback := &dummyMigrationConfig{component: ccm}
+	m, err := NewMigrationConfigFromComponentConfig(cfg, ccm, fallback)
+	if err != nil {
+		t.Fatalf("NewMigrationConfigFromComponentConfig: %v", err)
+	}
+
+	wantRunsHere, wantUnderLock := fallback.CloudNodeController()
+	if runsHere, underLock := m.CloudNodeController(); runsHere != wantRunsHere || underLock != wantUnderLock {
+		t.Errorf("unpinned cloud-node: got (%v, %v), want provider defaults (%v, %v)", runsHere, underLock, wantRunsHere, wantUnderLock)
+	}
+	wantRunsHere, wantUnderLock = fallback.RouteController()
+	if runsHere, underLock := m.RouteController(); runsHere != wantRunsHere || underLock != wantUnderLock {
+		t.Errorf("unpinned route: got (%v, %v), want provider defaults (%v, %v)", runsHere, underLock, wantRunsHere, wantUnderLock)
+	}
+	wantRunsHere, wantUnderLock = fallback.NodeIPAMController()
+	if runsHere, underLock := m.NodeIPAMController(); runsHere != wantRunsHere || underLock != wantUnderLock {
+		t.Errorf("unpinned node-ipam: got (%v, %v), want provider defaults (%v, %v)", runsHere, underLock, wantRunsHere, wantUnderLock)
+	}
+	wantRunsHere, wantUnderLock = fallback.CSIMigrationShim()
+	if runsHere, underLock := m.CSIMigrationShim(); runsHere != wantRunsHere || underLock != wantUnderLock {
+		t.Errorf("unpinned csi-migration-shim: got (%v, %v), want provider defaults (%v, %v)", runsHere, underLock, wantRunsHere, wantUnderLock)
+	}
+
+	// The one controller the operator did pin must reflect the pinned
+	// configuration, not the fallback.
+	if runsHere, underLock := m.ServiceController(); !runsHere || underLock {
+		t.Errorf("pinned service: got (%v, %v), want (true, false)", runsHere, underLock)
+	}
+}
+
+func TestNewMigrationConfigFromComponentConfig_NilConfigReturnsFallback(t *testing.T) {
+	fallback := &dummyMigrationConfig{component: kcm}
+	m, err := NewMigrationConfigFromComponentConfig(nil, kcm, fallback)
+	if err != nil {
+		t.Fatalf("NewMigrationConfigFromComponentConfig: %v", err)
+	}
+	if m != MigrationConfig(fallback) {
+		t.Errorf("expected a nil cfg to return fallback unchanged")
+	}
+}
+
+func TestNewMigrationConfigFromComponentConfig_NilFallbackUsesDummyDefaults(t *testing.T) {
+	cfg := &config.CloudProviderMigrationConfiguration{
+		Controllers: map[string]config.ControllerMigrationConfig{
+			"service": {Enabled: true, OwnerComponent: config.OwnerCloudControllerManager},
+		},
+	}
+	m, err := NewMigrationConfigFromComponentConfig(cfg, ccm, nil)
+	if err != nil {
+		t.Fatalf("NewMigrationConfigFromComponentConfig: %v", err)
+	}
+	want := (&dummyMigrationConfig{component: ccm}).CloudNodeController
+	wantRunsHere, wantUnderLock := want()
+	if runsHere, underLock := m.CloudNodeController(); runsHere != wantRunsHere || underLock != wantUnderLock {
+		t.Errorf("unpinned cloud-node with nil fallback: got (%v, %v), want dummyMigrationConfig defaults (%v, %v)", runsHere, underLock, wantRunsHere, wantUnderLock)
+	}
+}
+
+func TestNewMigrationConfigFromComponentConfig_RejectsInvalidOwnerComponent(t *testing.T) {
+	cfg := &config.CloudProviderMigrationConfiguration{
+		Controllers: map[string]config.ControllerMigrationConfig{
+			"service": {Enabled: true, OwnerComponent: "both-ish"},
+		},
+	}
+	if _, err := NewMigrationConfigFromComponentConfig(cfg, ccm, nil); err == nil {
+		t.Fatalf("expected an error for an invalid ownerComponent, got nil")
+	}
+}
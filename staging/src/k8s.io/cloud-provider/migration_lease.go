@@ -0,0 +1,361 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	migrationLeaseNamespace  = "kube-system"
+	migrationLeaseNamePrefix = "cloud-provider-migration-"
+	// leaseDuration is how long a held lease is honored before a
+	// competing holder may take it over, matching the lease's own
+	// renewTime bookkeeping.
+	leaseDuration = 15 * time.Second
+	renewInterval = 5 * time.Second
+
+	// preemptAfterHoldDuration is how long CCM must have continuously
+	// observed KCM holding a controller's lease (i.e. how long it's been
+	// since the lease's AcquireTime, with no intervening transition)
+	// before CCM is allowed to preempt it. It is expressed as a multiple
+	// of renewInterval so it reads as "N consecutive renewals": a CCM
+	// that is merely starting up, or flapping in a crash loop, observes
+	// KCM's lease for several ticks before it ever takes over, instead of
+	// seizing it the instant it sees KCM as the holder.
+	preemptAfterRenewals     = 3
+	preemptAfterHoldDuration = preemptAfterRenewals * renewInterval
+)
+
+var (
+	migrationLockHolder = metrics.NewGaugeVec(&metrics.GaugeOpts{
+		Name: "cloudprovider_migration_lock_holder",
+		Help: "Whether this component currently holds the migration lock for a controller (1) or not (0), labeled by controller and component.",
+	}, []string{"controller", "component"})
+
+	migrationLockTransitionsTotal = metrics.NewCounterVec(&metrics.CounterOpts{
+		Name: "cloudprovider_migration_lock_transitions_total",
+		Help: "Number of times the migration lock for a controller changed holder, labeled by controller and the new holder's component.",
+	}, []string{"controller", "component"})
+)
+
+func init() {
+	legacyregistry.MustRegister(migrationLockHolder)
+	legacyregistry.MustRegister(migrationLockTransitionsTotal)
+}
+
+// MigrationLeaseLock coordinates a single controller (e.g. "cloud-node",
+// "node-ipam") between kube-controller-manager and cloud-controller-manager
+// during a KCM->CCM migration, using a coordination.k8s.io Lease so only
+// one of the two components runs that controller's reconcile loop at a
+// time.
+//
+// CCM is always treated as the newer component: once it successfully
+// acquires a controller's lease, a KCM instance competing for the same
+// lease backs off until CCM's lease expires without being renewed. CCM may
+// not, however, preempt a KCM holder the instant it observes it: it must
+// wait out preemptAfterHoldDuration first (see canTakeOver), so a
+// restarting or crash-looping CCM can't thrash the lock back and forth
+// with KCM.
+type MigrationLeaseLock struct {
+	client         kubernetes.Interface
+	recorder       record.EventRecorder
+	controllerName string
+	component      string
+
+	// baseIdentity is "<component>/<podName>", stable for the life of
+	// this lock. identity is baseIdentity plus the generation of the
+	// acquisition currently held (or being attempted), and is what's
+	// actually written to the Lease's holderIdentity. Ownership checks
+	// compare baseIdentity, since generation resets to 0 across a
+	// restart of this process but the pod's claim to the lease doesn't.
+	baseIdentity string
+	generation   int64
+	identity     string
+}
+
+// NewMigrationLeaseLock returns a lock for controllerName, identifying this
+// holder as component ("ccm" or "kcm") running in pod podName.
+func NewMigrationLeaseLock(client kubernetes.Interface, recorder record.EventRecorder, controllerName, component, podName string) *MigrationLeaseLock {
+	base := fmt.Sprintf("%s/%s", component, podName)
+	return &MigrationLeaseLock{
+		client:         client,
+		recorder:       recorder,
+		controllerName: controllerName,
+		component:      component,
+		baseIdentity:   base,
+		identity:       identityForGeneration(base, 0),
+	}
+}
+
+// identityForGeneration builds the holderIdentity string for a given
+// generation of base: "<component>/<podName>#<generation>".
+func identityForGeneration(base string, generation int64) string {
+	return fmt.Sprintf("%s#%d", base, generation)
+}
+
+func (l *MigrationLeaseLock) leaseName() string {
+	return migrationLeaseNamePrefix + l.controllerName
+}
+
+// TryAcquire attempts to become (or remain) the holder of this controller's
+// migration lease. It returns true if the caller may proceed to run the
+// controller, false if another holder currently owns it.
+func (l *MigrationLeaseLock) TryAcquire(ctx context.Context) (bool, error) {
+	leases := l.client.CoordinationV1().Leases(migrationLeaseNamespace)
+
+	existing, err := leases.Get(ctx, l.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return l.acquire(ctx, leases, nil)
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting migration lease %q: %w", l.leaseName(), err)
+	}
+
+	if holderBaseIdentity(existing) == l.baseIdentity {
+		// We already hold it; just renew without bumping generation.
+		return l.renew(ctx, leases, existing)
+	}
+
+	if !l.canTakeOver(existing) {
+		l.setHolderMetric(false)
+		return false, nil
+	}
+	return l.acquire(ctx, leases, existing)
+}
+
+// canTakeOver reports whether this holder may take over an existing lease
+// held by someone else: either it has expired, or this is CCM contesting a
+// lease held by KCM for at least preemptAfterHoldDuration.
+func (l *MigrationLeaseLock) canTakeOver(lease *coordinationv1.Lease) bool {
+	if leaseExpired(lease) {
+		return true
+	}
+	if l.component != ccm || holderComponent(lease) != kcm {
+		return false
+	}
+	if lease.Spec.AcquireTime == nil {
+		return false
+	}
+	return time.Since(lease.Spec.AcquireTime.Time) >= preemptAfterHoldDuration
+}
+
+// acquire becomes the new holder of the lease, either creating it (existing
+// == nil) or taking it over from a different holder. Either way this is a
+// fresh acquisition, so it bumps this lock's generation and records a
+// transition.
+func (l *MigrationLeaseLock) acquire(ctx context.Context, leases coordinationv1client.LeaseInterface, existing *coordinationv1.Lease) (bool, error) {
+	l.generation++
+	l.identity = identityForGeneration(l.baseIdentity, l.generation)
+
+	now := metav1.NowMicro()
+	durationSeconds := int32(leaseDuration.Seconds())
+
+	if existing == nil {
+		transitions := int32(1)
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: l.leaseName(), Namespace: migrationLeaseNamespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+				LeaseTransitions:     &transitions,
+			},
+		}
+		_, err := leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// Lost the race to create it; the next TryAcquire call will see
+			// it via Get and fall into the normal takeover/renew path.
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("creating migration lease %q: %w", l.leaseName(), err)
+		}
+		l.recordTransition()
+		l.setHolderMetric(true)
+		return true, nil
+	}
+
+	transitions := int32(1)
+	if existing.Spec.LeaseTransitions != nil {
+		transitions = *existing.Spec.LeaseTransitions + 1
+	}
+	updated := existing.DeepCopy()
+	updated.Spec.HolderIdentity = &l.identity
+	updated.Spec.LeaseDurationSeconds = &durationSeconds
+	updated.Spec.AcquireTime = &now
+	updated.Spec.RenewTime = &now
+	updated.Spec.LeaseTransitions = &transitions
+
+	if _, err := leases.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			// Someone else took it over first; let the next tick retry.
+			return false, nil
+		}
+		return false, fmt.Errorf("updating migration lease %q: %w", l.leaseName(), err)
+	}
+	l.recordTransition()
+	l.setHolderMetric(true)
+	return true, nil
+}
+
+// renew extends this holder's existing claim on lease without changing its
+// generation or AcquireTime.
+func (l *MigrationLeaseLock) renew(ctx context.Context, leases coordinationv1client.LeaseInterface, lease *coordinationv1.Lease) (bool, error) {
+	now := metav1.NowMicro()
+	durationSeconds := int32(leaseDuration.Seconds())
+	updated := lease.DeepCopy()
+	updated.Spec.HolderIdentity = &l.identity
+	updated.Spec.LeaseDurationSeconds = &durationSeconds
+	updated.Spec.RenewTime = &now
+
+	if _, err := leases.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			// Someone else renewed first; let the next tick retry.
+			return false, nil
+		}
+		return false, fmt.Errorf("updating migration lease %q: %w", l.leaseName(), err)
+	}
+	l.setHolderMetric(true)
+	return true, nil
+}
+
+// Release gives up this holder's claim on the lease immediately, instead of
+// waiting for it to expire, so the other component doesn't have to wait out
+// leaseDuration during a clean shutdown.
+func (l *MigrationLeaseLock) Release(ctx context.Context) error {
+	leases := l.client.CoordinationV1().Leases(migrationLeaseNamespace)
+	lease, err := leases.Get(ctx, l.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting migration lease %q: %w", l.leaseName(), err)
+	}
+	if holderBaseIdentity(lease) != l.baseIdentity {
+		return nil
+	}
+
+	if err := leases.Delete(ctx, l.leaseName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting migration lease %q: %w", l.leaseName(), err)
+	}
+	l.setHolderMetric(false)
+	return nil
+}
+
+// Run periodically renews the lease until stopCh is closed, invoking
+// onAcquired/onLost whenever this holder's acquisition status changes.
+func (l *MigrationLeaseLock) Run(ctx context.Context, onAcquired, onLost func()) {
+	held := false
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := l.TryAcquire(ctx)
+		if err != nil {
+			klog.Errorf("renewing migration lease %q: %v", l.leaseName(), err)
+		}
+		if acquired && !held {
+			held = true
+			if onAcquired != nil {
+				onAcquired()
+			}
+		} else if !acquired && held {
+			held = false
+			if onLost != nil {
+				onLost()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *MigrationLeaseLock) recordTransition() {
+	migrationLockTransitionsTotal.WithLabelValues(l.controllerName, l.component).Inc()
+	if l.recorder != nil {
+		l.recorder.Eventf(&v1.ObjectReference{Kind: "Lease", Name: l.leaseName(), Namespace: migrationLeaseNamespace},
+			v1.EventTypeNormal, "MigrationLockAcquired", "%s acquired the migration lock for controller %q", l.identity, l.controllerName)
+	}
+}
+
+func (l *MigrationLeaseLock) setHolderMetric(held bool) {
+	value := 0.0
+	if held {
+		value = 1.0
+	}
+	migrationLockHolder.WithLabelValues(l.controllerName, l.component).Set(value)
+}
+
+func holderIdentity(lease *coordinationv1.Lease) string {
+	if lease == nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// holderBaseIdentity strips the "#<generation>" suffix MigrationLeaseLock
+// appends to its identity, returning "<component>/<podName>". This is what
+// ownership checks compare against, since a holder's in-memory generation
+// counter resets to 0 across a process restart even though its claim on
+// the lease (tied to its pod identity) doesn't.
+func holderBaseIdentity(lease *coordinationv1.Lease) string {
+	identity := holderIdentity(lease)
+	if i := strings.LastIndex(identity, "#"); i >= 0 {
+		return identity[:i]
+	}
+	return identity
+}
+
+// holderComponent extracts the component ("ccm" or "kcm") from a holder
+// identity of the form "<component>/<podName>#<generation>".
+func holderComponent(lease *coordinationv1.Lease) string {
+	identity := holderIdentity(lease)
+	for i := 0; i < len(identity); i++ {
+		if identity[i] == '/' {
+			return identity[:i]
+		}
+	}
+	return identity
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
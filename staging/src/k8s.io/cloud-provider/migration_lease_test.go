@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTryAcquire_CreatesLeaseAndRenewsWithoutTransition(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	lock := NewMigrationLeaseLock(client, nil, "service", kcm, "kcm-0")
+
+	acquired, err := lock.TryAcquire(context.Background())
+	if err != nil || !acquired {
+		t.Fatalf("expected first TryAcquire to succeed, got acquired=%v err=%v", acquired, err)
+	}
+
+	lease, err := client.CoordinationV1().Leases(migrationLeaseNamespace).Get(context.Background(), lock.leaseName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	if holderBaseIdentity(lease) != "kube-controller-manager/kcm-0" {
+		t.Fatalf("unexpected holder base identity: %q", holderBaseIdentity(lease))
+	}
+	firstAcquire := lease.Spec.AcquireTime
+
+	acquired, err = lock.TryAcquire(context.Background())
+	if err != nil || !acquired {
+		t.Fatalf("expected renew to succeed, got acquired=%v err=%v", acquired, err)
+	}
+	lease, err = client.CoordinationV1().Leases(migrationLeaseNamespace).Get(context.Background(), lock.leaseName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	if lease.Spec.AcquireTime.Time != firstAcquire.Time {
+		t.Errorf("expected AcquireTime to be unchanged across a renewal, got %v want %v", lease.Spec.AcquireTime, firstAcquire)
+	}
+	if *lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("expected 1 transition after create+renew, got %d", *lease.Spec.LeaseTransitions)
+	}
+}
+
+func TestTryAcquire_CCMCannotImmediatelyPreemptKCM(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	kcmLock := NewMigrationLeaseLock(client, nil, "service", kcm, "kcm-0")
+	if acquired, err := kcmLock.TryAcquire(context.Background()); err != nil || !acquired {
+		t.Fatalf("expected KCM to acquire the lease, got acquired=%v err=%v", acquired, err)
+	}
+
+	ccmLock := NewMigrationLeaseLock(client, nil, "service", ccm, "ccm-0")
+	acquired, err := ccmLock.TryAcquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if acquired {
+		t.Fatalf("expected CCM not to preempt a freshly-acquired KCM lease")
+	}
+}
+
+func TestTryAcquire_CCMPreemptsAfterHoldDuration(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	kcmLock := NewMigrationLeaseLock(client, nil, "service", kcm, "kcm-0")
+	if acquired, err := kcmLock.TryAcquire(context.Background()); err != nil || !acquired {
+		t.Fatalf("expected KCM to acquire the lease, got acquired=%v err=%v", acquired, err)
+	}
+
+	// Backdate AcquireTime and RenewTime past preemptAfterHoldDuration, as
+	// if KCM had genuinely held it that long, without it ever expiring.
+	lease, err := client.CoordinationV1().Leases(migrationLeaseNamespace).Get(context.Background(), kcmLock.leaseName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	past := metav1.NewMicroTime(time.Now().Add(-(preemptAfterHoldDuration + time.Second)))
+	lease.Spec.AcquireTime = &past
+	lease.Spec.RenewTime = &past
+	if _, err := client.CoordinationV1().Leases(migrationLeaseNamespace).Update(context.Background(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("backdating lease: %v", err)
+	}
+
+	ccmLock := NewMigrationLeaseLock(client, nil, "service", ccm, "ccm-0")
+	acquired, err := ccmLock.TryAcquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !acquired {
+		t.Fatalf("expected CCM to preempt a KCM lease held past preemptAfterHoldDuration")
+	}
+}
+
+func TestTryAcquire_ExpiredLeaseTakenOverImmediately(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	kcmLock := NewMigrationLeaseLock(client, nil, "service", kcm, "kcm-0")
+	if acquired, err := kcmLock.TryAcquire(context.Background()); err != nil || !acquired {
+		t.Fatalf("expected KCM to acquire the lease, got acquired=%v err=%v", acquired, err)
+	}
+
+	lease, err := client.CoordinationV1().Leases(migrationLeaseNamespace).Get(context.Background(), kcmLock.leaseName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	expired := metav1.NewMicroTime(time.Now().Add(-2 * leaseDuration))
+	lease.Spec.AcquireTime = &expired
+	lease.Spec.RenewTime = &expired
+	if _, err := client.CoordinationV1().Leases(migrationLeaseNamespace).Update(context.Background(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("backdating lease: %v", err)
+	}
+
+	otherKCMLock := NewMigrationLeaseLock(client, nil, "service", kcm, "kcm-1")
+	acquired, err := otherKCMLock.TryAcquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !acquired {
+		t.Fatalf("expected a second KCM instance to take over an expired lease immediately")
+	}
+}
+
+// TestTryAcquire_ConcurrentKCMInstancesExactlyOneWins simulates several
+// replicas of the same component racing to create/take over a single
+// controller's lease concurrently. Exactly one of them must end up holding
+// it, and nobody should see an error: the losers' Create/Update conflicts
+// are expected outcomes, not failures.
+func TestTryAcquire_ConcurrentKCMInstancesExactlyOneWins(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	const replicas = 10
+	locks := make([]*MigrationLeaseLock, replicas)
+	for i := 0; i < replicas; i++ {
+		locks[i] = NewMigrationLeaseLock(client, nil, "service", kcm, fmt.Sprintf("kcm-%d", i))
+	}
+
+	var wg sync.WaitGroup
+	results := make([]bool, replicas)
+	errs := make([]error, replicas)
+	for i := range locks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = locks[i].TryAcquire(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	wins := 0
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("replica %d: unexpected error: %v", i, err)
+		}
+		if results[i] {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("expected exactly 1 of %d concurrent replicas to win, got %d", replicas, wins)
+	}
+}
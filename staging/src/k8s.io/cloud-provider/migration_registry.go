@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// MigrationConfigFactory builds a provider's MigrationConfig from its
+// cloud config, the same way Factory builds a provider's Interface.
+type MigrationConfigFactory func(config io.Reader) (MigrationConfig, error)
+
+var migrationConfigsMutex sync.Mutex
+var migrationConfigs = make(map[string]MigrationConfigFactory)
+
+// RegisterMigrationConfig registers a MigrationConfigFactory for the named
+// provider (e.g. "aws", "gce", "azure", "openstack"), analogous to
+// RegisterCloudProvider. Each provider ships its own migration schedule:
+// AWS may have already migrated ServiceController but not
+// NodeIPAMController, while OpenStack may still need RouteController in
+// KCM. Not all providers need to call this; those that don't get
+// dummyMigrationConfig's defaults.
+func RegisterMigrationConfig(name string, factory MigrationConfigFactory) {
+	migrationConfigsMutex.Lock()
+	defer migrationConfigsMutex.Unlock()
+	if _, found := migrationConfigs[name]; found {
+		klog.Fatalf("MigrationConfig %q was registered twice", name)
+	}
+	klog.V(1).Infof("Registered MigrationConfig %q", name)
+	migrationConfigs[name] = factory
+}
+
+// GetMigrationConfig returns the MigrationConfigFactory registered for
+// name, if any.
+func GetMigrationConfig(name string) (MigrationConfigFactory, bool) {
+	migrationConfigsMutex.Lock()
+	defer migrationConfigsMutex.Unlock()
+	factory, found := migrationConfigs[name]
+	return factory, found
+}
+
+// InitMigrationConfig resolves the MigrationConfig for the named provider,
+// falling back to dummyMigrationConfig when none is registered, and then
+// binds it to component (as SetComponent does), validating that component
+// is one the resolved MigrationConfig actually supports.
+func InitMigrationConfig(name, component string, config io.Reader) (MigrationConfig, error) {
+	var migrationConfig MigrationConfig
+
+	if factory, found := GetMigrationConfig(name); found {
+		built, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("could not init MigrationConfig for provider %q: %w", name, err)
+		}
+		migrationConfig = built
+	} else {
+		klog.V(1).Infof("no MigrationConfig registered for provider %q, using defaults", name)
+		migrationConfig = &dummyMigrationConfig{}
+	}
+
+	if err := migrationConfig.SetComponent(component); err != nil {
+		return nil, fmt.Errorf("provider %q does not support component %q: %w", name, component, err)
+	}
+
+	return migrationConfig, nil
+}
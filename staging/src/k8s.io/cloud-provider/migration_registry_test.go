@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// withCleanMigrationConfigs registers cleanup to restore migrationConfigs
+// to empty after the test, so registrations made by one test don't leak
+// into another.
+func withCleanMigrationConfigs(t *testing.T) {
+	t.Cleanup(func() {
+		migrationConfigsMutex.Lock()
+		defer migrationConfigsMutex.Unlock()
+		migrationConfigs = make(map[string]MigrationConfigFactory)
+	})
+}
+
+func fakeMigrationConfigFactory(cfg MigrationConfig) MigrationConfigFactory {
+	return func(io.Reader) (MigrationConfig, error) {
+		return cfg, nil
+	}
+}
+
+func TestRegisterMigrationConfigTwicePanics(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	RegisterMigrationConfig("dummy-provider", fakeMigrationConfigFactory(&dummyMigrationConfig{}))
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected registering the same provider twice to panic via klog.Fatalf")
+		}
+	}()
+	RegisterMigrationConfig("dummy-provider", fakeMigrationConfigFactory(&dummyMigrationConfig{}))
+}
+
+func TestGetMigrationConfigNotFound(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	if _, found := GetMigrationConfig("does-not-exist"); found {
+		t.Fatal("expected no MigrationConfigFactory to be registered")
+	}
+}
+
+func TestInitMigrationConfigFallsBackToDummy(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	mc, err := InitMigrationConfig("unregistered-provider", ccm, nil)
+	if err != nil {
+		t.Fatalf("InitMigrationConfig returned error: %v", err)
+	}
+	if _, ok := mc.(*dummyMigrationConfig); !ok {
+		t.Fatalf("expected dummyMigrationConfig fallback, got %T", mc)
+	}
+	if mc.GetComponent() != ccm {
+		t.Errorf("GetComponent() = %q, want %q", mc.GetComponent(), ccm)
+	}
+}
+
+func TestInitMigrationConfigUsesRegisteredFactory(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	RegisterMigrationConfig("fake-provider", fakeMigrationConfigFactory(&dummyMigrationConfig{}))
+
+	mc, err := InitMigrationConfig("fake-provider", kcm, nil)
+	if err != nil {
+		t.Fatalf("InitMigrationConfig returned error: %v", err)
+	}
+	if mc.GetComponent() != kcm {
+		t.Errorf("GetComponent() = %q, want %q", mc.GetComponent(), kcm)
+	}
+}
+
+func TestInitMigrationConfigFactoryError(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	RegisterMigrationConfig("broken-provider", func(io.Reader) (MigrationConfig, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+
+	if _, err := InitMigrationConfig("broken-provider", ccm, nil); err == nil {
+		t.Fatal("expected an error when the factory fails")
+	}
+}
+
+func TestInitMigrationConfigRejectsInvalidComponent(t *testing.T) {
+	withCleanMigrationConfigs(t)
+
+	_, err := InitMigrationConfig("unregistered-provider", "not-a-component", nil)
+	if err == nil {
+		t.Fatal("expected SetComponent validation to fail through InitMigrationConfig")
+	}
+	if !strings.Contains(err.Error(), "does not support component") {
+		t.Errorf("error = %v, want it to wrap the SetComponent failure", err)
+	}
+}
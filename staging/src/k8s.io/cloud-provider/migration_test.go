@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDummyMigrationConfigMatrix enumerates every (component, controller)
+// pair dummyMigrationConfig knows about and asserts the expected
+// (runsHere, underLock) result, so a future edit to one controller's
+// defaults can't silently change another's.
+func TestDummyMigrationConfigMatrix(t *testing.T) {
+	controllers := []struct {
+		name string
+		fn   func(*dummyMigrationConfig) (bool, bool)
+	}{
+		{"cloud-node", (*dummyMigrationConfig).CloudNodeController},
+		{"service", (*dummyMigrationConfig).ServiceController},
+		{"route", (*dummyMigrationConfig).RouteController},
+		{"node-ipam", (*dummyMigrationConfig).NodeIPAMController},
+		{"persistentvolume-label", (*dummyMigrationConfig).PersistentVolumeLabelController},
+		{"csi-migration-shim", (*dummyMigrationConfig).CSIMigrationShim},
+	}
+
+	wantRunsHere := map[string]map[string]bool{
+		ccm: {
+			"cloud-node":             true,
+			"service":                true,
+			"route":                  false,
+			"node-ipam":              true,
+			"persistentvolume-label": true,
+			"csi-migration-shim":     true,
+		},
+		kcm: {
+			"cloud-node":             true,
+			"service":                false,
+			"route":                  true,
+			"node-ipam":              false,
+			"persistentvolume-label": false,
+			"csi-migration-shim":     true,
+		},
+	}
+
+	// only cloud-node is contended between components today, so it's the
+	// only controller dummyMigrationConfig gates behind a migration lock.
+	wantUnderLock := map[string]bool{"cloud-node": true}
+
+	for _, component := range []string{ccm, kcm} {
+		m := &dummyMigrationConfig{component: component}
+		for _, c := range controllers {
+			runsHere, underLock := c.fn(m)
+			if runsHere != wantRunsHere[component][c.name] {
+				t.Errorf("component %q controller %q: runsHere = %v, want %v", component, c.name, runsHere, wantRunsHere[component][c.name])
+			}
+			if underLock != wantUnderLock[c.name] {
+				t.Errorf("component %q controller %q: underLock = %v, want %v", component, c.name, underLock, wantUnderLock[c.name])
+			}
+		}
+	}
+}
+
+// TestSetComponentRejectsUnknownValues fuzzes SetComponent to make sure
+// only "ccm"/"kcm" are ever accepted, and that a rejected value never
+// mutates the existing component.
+func TestSetComponentRejectsUnknownValues(t *testing.T) {
+	for _, seed := range []string{"", "ccm", "kcm", "CCM", "ccm ", " kcm", "both", "cloud-controller-manager"} {
+		t.Run(seed, func(t *testing.T) {
+			m := &dummyMigrationConfig{component: kcm}
+			err := m.SetComponent(seed)
+			if seed == ccm || seed == kcm {
+				if err != nil {
+					t.Fatalf("SetComponent(%q) = %v, want nil", seed, err)
+				}
+				if m.component != seed {
+					t.Fatalf("SetComponent(%q): component = %q, want %q", seed, m.component, seed)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("SetComponent(%q) = nil error, want error", seed)
+			}
+			if m.component != kcm {
+				t.Fatalf("SetComponent(%q): component changed to %q on error, want unchanged %q", seed, m.component, kcm)
+			}
+		})
+	}
+}
+
+func FuzzSetComponent(f *testing.F) {
+	for _, seed := range []string{"", "ccm", "kcm", "both", "CCM"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, component string) {
+		m := &dummyMigrationConfig{component: kcm}
+		err := m.SetComponent(component)
+		if component != ccm && component != kcm {
+			if err == nil {
+				t.Fatalf("SetComponent(%q) = nil error, want error", component)
+			}
+			if m.component != kcm {
+				t.Fatalf("SetComponent(%q) mutated component to %q on error", component, m.component)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("SetComponent(%q) = %v, want nil", component, err)
+		}
+	})
+}
+
+// goldenDescribe is the known-good Describe() output for dummyMigrationConfig
+// as of this package's current controller set. Adding, removing, or
+// reordering a controller here is a deliberate interface change and should
+// be reviewed as such, not an accidental side effect of unrelated work.
+var goldenDescribe = map[string][]ControllerMigrationStatus{
+	ccm: {
+		{ControllerName: "cloud-node", RunsHere: true, UnderLock: true},
+		{ControllerName: "service", RunsHere: true, UnderLock: false},
+		{ControllerName: "route", RunsHere: false, UnderLock: false},
+		{ControllerName: "node-ipam", RunsHere: true, UnderLock: false},
+		{ControllerName: "persistentvolume-label", RunsHere: true, UnderLock: false},
+		{ControllerName: "csi-migration-shim", RunsHere: true, UnderLock: false},
+	},
+	kcm: {
+		{ControllerName: "cloud-node", RunsHere: true, UnderLock: true},
+		{ControllerName: "service", RunsHere: false, UnderLock: false},
+		{ControllerName: "route", RunsHere: true, UnderLock: false},
+		{ControllerName: "node-ipam", RunsHere: false, UnderLock: false},
+		{ControllerName: "persistentvolume-label", RunsHere: false, UnderLock: false},
+		{ControllerName: "csi-migration-shim", RunsHere: true, UnderLock: false},
+	},
+}
+
+func TestDummyMigrationConfigDescribeGolden(t *testing.T) {
+	for component, want := range goldenDescribe {
+		m := &dummyMigrationConfig{component: component}
+		got := m.Describe()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("component %q: Describe() = %#v, want %#v", component, got, want)
+		}
+	}
+}
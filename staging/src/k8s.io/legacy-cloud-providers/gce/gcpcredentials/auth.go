@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import (
+	"context"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+	"k8s.io/klog/v2"
+)
+
+// DefaultTokenScopes are the OAuth scopes requested of ambient credentials
+// resolved through cloud.google.com/go/auth. cloudPlatformScopePrefix alone
+// is sufficient to pull from GCR/Artifact Registry and implies the
+// storage scope, but the read-only storage scope is requested alongside it
+// so a credential scoped down to just storage access is still accepted.
+var DefaultTokenScopes = []string{
+	cloudPlatformScopePrefix,
+	storageScopePrefix + ".read_only",
+}
+
+// resolveAmbientCredentials resolves credentials the same way the gcloud
+// and Google Cloud client libraries do: GOOGLE_APPLICATION_CREDENTIALS,
+// an external-account JSON file (Workload Identity Federation, including
+// GKE Workload Identity on non-GCE clusters), an impersonated service
+// account, or finally the GCE/GKE metadata server. It returns an error if
+// none of those sources are configured, which is expected and not logged
+// above V(4) on a bare GCE VM relying solely on the metadata server.
+func resolveAmbientCredentials(ctx context.Context) (*auth.Credentials, error) {
+	return credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes: DefaultTokenScopes,
+	})
+}
+
+// containerRegistryEntry returns the docker config entry to use for pulling
+// from a Google container registry, preferring a token minted through
+// cloud.google.com/go/auth (so Workload Identity Federation, external
+// accounts, and impersonation all work) and falling back to the raw GCE
+// metadata server calls when no ambient credentials are configured, which
+// is the only path available on a bare GCE VM with just a node service
+// account.
+func containerRegistryEntry(ctx context.Context) (DockerConfigEntry, error) {
+	creds, err := resolveAmbientCredentials(ctx)
+	if err != nil {
+		klog.V(4).Infof("no ambient Google credentials available via cloud.google.com/go/auth, falling back to the GCE metadata server: %v", err)
+		return metadataContainerRegistryEntry()
+	}
+
+	tok, err := creds.Token(ctx)
+	if err != nil {
+		klog.V(2).Infof("ambient Google credentials failed to mint a token, falling back to the GCE metadata server: %v", err)
+		return metadataContainerRegistryEntry()
+	}
+
+	// cloud.google.com/go/auth doesn't expose the account email uniformly
+	// across credential types (e.g. it isn't meaningful for an external
+	// account), so it is left blank here; the registry only checks
+	// Username/Password.
+	return DockerConfigEntry{
+		Username: "_token",
+		Password: tok.Value,
+	}, nil
+}
+
+// ContainerRegistryEnabled reports whether credentials are available for
+// pulling from a Google container registry: either ambient credentials via
+// cloud.google.com/go/auth (GKE Workload Identity, external accounts,
+// impersonation, or GOOGLE_APPLICATION_CREDENTIALS), or, when running on a
+// bare GCE VM with none of those configured, a node service account with
+// the storage scope.
+func ContainerRegistryEnabled() bool {
+	if _, err := resolveAmbientCredentials(context.Background()); err == nil {
+		return true
+	}
+	return OnGCEVM() && HasStorageScope()
+}
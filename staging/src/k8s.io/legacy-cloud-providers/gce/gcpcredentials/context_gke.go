@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// projectedTokenPath is where kubelet mounts the projected
+	// service-account token volume that GKE workload identity federation
+	// exchanges for a registry-scoped token.
+	projectedTokenPath  = "/var/run/secrets/tokens/gcp-ksa/token"
+	stsTokenExchangeURL = "https://sts.googleapis.com/v1/token"
+)
+
+// gkeContext is the Context for GKE clusters (including non-Google ones
+// federated with GKE Workload Identity): it reads the projected
+// service-account token kubelet mounts into the pod and exchanges it via
+// the Security Token Service for a token scoped to pull from Google
+// registries, without requiring a node service account.
+type gkeContext struct {
+	// TokenPath overrides projectedTokenPath; used in tests.
+	TokenPath string
+}
+
+func (gkeContext) Name() string { return "gke" }
+
+// Detect reports whether a projected service-account token is mounted,
+// which GKE only does for workloads configured with Workload Identity.
+func (c gkeContext) Detect() bool {
+	_, err := ioutil.ReadFile(c.tokenPath())
+	return err == nil
+}
+
+func (gkeContext) RegistryHosts() []string {
+	return containerRegistryUrls
+}
+
+func (c gkeContext) tokenPath() string {
+	if c.TokenPath != "" {
+		return c.TokenPath
+	}
+	return projectedTokenPath
+}
+
+func (c gkeContext) Fetch() (DockerConfigJSON, error) {
+	saToken, err := ioutil.ReadFile(c.tokenPath())
+	if err != nil {
+		return DockerConfigJSON{}, fmt.Errorf("gke context: reading projected token: %w", err)
+	}
+
+	accessToken, err := exchangeForAccessToken(strings.TrimSpace(string(saToken)))
+	if err != nil {
+		return DockerConfigJSON{}, fmt.Errorf("gke context: %w", err)
+	}
+
+	entry := DockerConfigEntry{
+		Username: "_token",
+		Password: accessToken,
+	}
+	cfg := DockerConfig{}
+	for _, host := range containerRegistryUrls {
+		cfg[host] = entry
+	}
+	return DockerConfigJSON{Auths: cfg}, nil
+}
+
+// stsTokenResponse is the subset of the STS token exchange response this
+// package consumes. See
+// https://cloud.google.com/iam/docs/reference/sts/rest/v1/TopLevel/token
+type stsTokenResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+// exchangeForAccessToken exchanges a Kubernetes-issued service-account JWT
+// for a Google access token via the workload identity federation STS
+// endpoint.
+func exchangeForAccessToken(saToken string) (string, error) {
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+		"subject_token":        {saToken},
+		"scope":                {cloudPlatformScopePrefix},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, stsTokenExchangeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed with status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var parsed stsTokenResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("parsing token exchange response: %w", err)
+	}
+	return parsed.AccessToken, nil
+}
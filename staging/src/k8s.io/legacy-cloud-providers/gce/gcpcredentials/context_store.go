@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Context is one way of obtaining a DockerConfigJSON for Google registries,
+// analogous to a buildx builder context: it knows how to detect whether it
+// applies to the node it's running on, how to fetch credentials when it
+// does, and which registry hosts it's willing to answer for.
+type Context interface {
+	// Name identifies the context, e.g. "gce" or "gke".
+	Name() string
+	// Detect reports whether this context applies on the current node,
+	// equivalent to OnGCEVM for the gce context.
+	Detect() bool
+	// Fetch returns the docker config for this context's credentials.
+	Fetch() (DockerConfigJSON, error)
+	// RegistryHosts returns the registry host globs this context is
+	// authoritative for, matched the same way containerRegistryUrls is.
+	RegistryHosts() []string
+}
+
+// ContextStore holds the set of Contexts a binary knows about and selects
+// among them either by explicit name or by auto-detection, so the same
+// binary can run unmodified on a GCE VM, a GKE Autopilot node, or a hybrid
+// cluster.
+type ContextStore struct {
+	mu       sync.RWMutex
+	contexts map[string]Context
+	order    []string // preserves registration order for deterministic auto-detection
+}
+
+// NewContextStore returns an empty ContextStore.
+func NewContextStore() *ContextStore {
+	return &ContextStore{contexts: map[string]Context{}}
+}
+
+// Register adds ctx to the store, keyed by its Name(). Registering a name
+// that already exists replaces the previous Context.
+func (s *ContextStore) Register(ctx Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.contexts[ctx.Name()]; !exists {
+		s.order = append(s.order, ctx.Name())
+	}
+	s.contexts[ctx.Name()] = ctx
+}
+
+// Get returns the Context registered under name.
+func (s *ContextStore) Get(name string) (Context, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ctx, ok := s.contexts[name]
+	return ctx, ok
+}
+
+// Detect returns the first registered Context (in registration order) that
+// reports it applies to the current node.
+func (s *ContextStore) Detect() (Context, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, name := range s.order {
+		if ctx := s.contexts[name]; ctx.Detect() {
+			return ctx, true
+		}
+	}
+	return nil, false
+}
+
+// Provide returns the docker config for image from whichever registered
+// Context both applies to this node and is authoritative for image's
+// registry host. It is the ContextStore equivalent of
+// gcpcredentials.ProvideContainerRegistry, generalized across contexts.
+func (s *ContextStore) Provide(image string) DockerConfig {
+	cfg := DockerConfig{}
+
+	host := registryHostname(image)
+	if host == "" {
+		return cfg
+	}
+
+	ctx, ok := s.Detect()
+	if !ok {
+		return cfg
+	}
+
+	var owns bool
+	for _, glob := range ctx.RegistryHosts() {
+		if matchesRegistryGlob(glob, host) {
+			owns = true
+			break
+		}
+	}
+	if !owns {
+		return cfg
+	}
+
+	dockerCfgJSON, err := ctx.Fetch()
+	if err != nil {
+		return cfg
+	}
+	for registry, entry := range dockerCfgJSON.Auths {
+		cfg[registry] = entry
+	}
+	return cfg
+}
+
+// DefaultContextStore is populated with the built-in "gce" and "gke"
+// contexts at package init time; most callers can use it directly instead
+// of assembling their own ContextStore.
+var DefaultContextStore = newDefaultContextStore()
+
+func newDefaultContextStore() *ContextStore {
+	store := NewContextStore()
+	// gke is registered before gce: gceContext.Detect is just OnGCEVM,
+	// which is true on every GKE node too (a GKE node is a GCE VM), so if
+	// gce were registered first it would always win and Workload Identity
+	// credentials would never be preferred on a hybrid/Workload-Identity
+	// GKE node, contrary to containerRegistryProvider's documented intent.
+	store.Register(&gkeContext{})
+	store.Register(&gceContext{})
+	return store
+}
+
+// gceContext is the Context wrapping the existing GCE metadata-server
+// logic in this package.
+type gceContext struct{}
+
+func (gceContext) Name() string { return "gce" }
+
+func (gceContext) Detect() bool { return OnGCEVM() }
+
+func (gceContext) RegistryHosts() []string { return containerRegistryUrls }
+
+func (gceContext) Fetch() (DockerConfigJSON, error) {
+	entry, err := metadataContainerRegistryEntry()
+	if err != nil {
+		return DockerConfigJSON{}, fmt.Errorf("gce context: %w", err)
+	}
+	cfg := DockerConfig{}
+	for _, host := range containerRegistryUrls {
+		cfg[host] = entry
+	}
+	return DockerConfigJSON{Auths: cfg}, nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+type fakeContext struct {
+	name    string
+	detect  bool
+	hosts   []string
+	fetched DockerConfigJSON
+}
+
+func (f *fakeContext) Name() string            { return f.name }
+func (f *fakeContext) Detect() bool            { return f.detect }
+func (f *fakeContext) RegistryHosts() []string { return f.hosts }
+func (f *fakeContext) Fetch() (DockerConfigJSON, error) {
+	return f.fetched, nil
+}
+
+func TestContextStoreDetectPrefersFirstMatch(t *testing.T) {
+	store := NewContextStore()
+	store.Register(&fakeContext{name: "a", detect: false})
+	store.Register(&fakeContext{name: "b", detect: true})
+	store.Register(&fakeContext{name: "c", detect: true})
+
+	ctx, ok := store.Detect()
+	if !ok {
+		t.Fatalf("expected a context to be detected")
+	}
+	if ctx.Name() != "b" {
+		t.Errorf("expected the first matching context (b) to win, got %q", ctx.Name())
+	}
+}
+
+func TestContextStoreProvideScopesToDetectedContextAndHost(t *testing.T) {
+	store := NewContextStore()
+	store.Register(&fakeContext{
+		name:   "gke",
+		detect: true,
+		hosts:  []string{"*.pkg.dev"},
+		fetched: DockerConfigJSON{
+			Auths: DockerConfig{"*.pkg.dev": DockerConfigEntry{Username: "_token", Password: "gke-token"}},
+		},
+	})
+
+	cfg := store.Provide("us-central1-docker.pkg.dev/my-project/my-repo/app:v1")
+	entry, ok := cfg["*.pkg.dev"]
+	if !ok || entry.Password != "gke-token" {
+		t.Errorf("expected the gke context's credentials, got %v", cfg)
+	}
+
+	if cfg := store.Provide("quay.io/some/app:v1"); len(cfg) != 0 {
+		t.Errorf("expected no credentials for a host the context doesn't own, got %v", cfg)
+	}
+}
+
+// TestDefaultContextStorePrefersGKEOnWorkloadIdentityNode pins the ordering
+// bug this guards against: a GKE node is also a GCE VM, so gceContext's
+// Detect (OnGCEVM) is true there too. If gce were registered before gke,
+// ContextStore.Detect would always return it and a node with a projected
+// Workload Identity token would never get gke credentials. It seeds both
+// real contexts, not fakeContexts, so a future reordering of
+// newDefaultContextStore's registration is actually caught.
+func TestDefaultContextStorePrefersGKEOnWorkloadIdentityNode(t *testing.T) {
+	dir := t.TempDir()
+
+	productNameFile := filepath.Join(dir, "product_name")
+	if err := ioutil.WriteFile(productNameFile, []byte("Google Compute Engine"), 0644); err != nil {
+		t.Fatalf("writing fake product_name file: %v", err)
+	}
+	origProductNameFile := gceProductNameFile
+	gceProductNameFile = productNameFile
+	t.Cleanup(func() { gceProductNameFile = origProductNameFile })
+
+	tokenPath := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("fake-sa-token"), 0600); err != nil {
+		t.Fatalf("writing fake projected token: %v", err)
+	}
+
+	store := NewContextStore()
+	store.Register(&gkeContext{TokenPath: tokenPath})
+	store.Register(&gceContext{})
+
+	ctx, ok := store.Detect()
+	if !ok {
+		t.Fatalf("expected a context to be detected")
+	}
+	if ctx.Name() != "gke" {
+		t.Errorf("expected gke to be preferred on a node that is both a GCE VM and has a projected Workload Identity token, got %q", ctx.Name())
+	}
+
+	// Sanity check the failure mode this test would catch: with gce
+	// registered first, gce wins instead.
+	reversed := NewContextStore()
+	reversed.Register(&gceContext{})
+	reversed.Register(&gkeContext{TokenPath: tokenPath})
+	ctx, ok = reversed.Detect()
+	if !ok || ctx.Name() != "gce" {
+		t.Fatalf("expected this sanity check to confirm gce wins when registered first, got %v, %v", ctx, ok)
+	}
+}
+
+func TestContextStoreProvideNoDetectedContext(t *testing.T) {
+	store := NewContextStore()
+	store.Register(&fakeContext{name: "gce", detect: false, hosts: []string{"gcr.io"}})
+
+	if cfg := store.Provide("gcr.io/my-project/my-image:v1"); len(cfg) != 0 {
+		t.Errorf("expected no credentials when no context detects, got %v", cfg)
+	}
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package gcpcredentials
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os/exec"
@@ -43,6 +46,11 @@ const (
 	cloudPlatformScopePrefix  = "https://www.googleapis.com/auth/cloud-platform"
 	defaultServiceAccount     = "default/"
 	metadataHTTPClientTimeout = time.Second * 10
+
+	// maxReadLength bounds how much of a metadata/URL-sourced docker config
+	// response readURL will buffer, so a misbehaving or malicious endpoint
+	// can't exhaust memory on the node.
+	maxReadLength = 10 * 1024 * 1024
 )
 
 // Product file path that contains the cloud service name.
@@ -81,8 +89,9 @@ type dockerConfigURLKeyProvider struct {
 }
 
 // A DockerConfigProvider that provides a dockercfg with:
-//    Username: "_token"
-//    Password: "{access token from metadata}"
+//
+//	Username: "_token"
+//	Password: "{access token from metadata}"
 type containerRegistryProvider struct {
 	metadataProvider
 }
@@ -202,15 +211,13 @@ func ProvideDockerConfigURLKey(image string) DockerConfig {
 	if url, err := readURL(dockerConfigURLKey, httpClient, metadataHeader); err != nil {
 		klog.Errorf("while reading 'google-dockercfg-url' metadata: %v", err)
 	} else {
-		if strings.HasPrefix(string(url), "http") {
-			if cfg, err := ReadDockerConfigFileFromURL(string(url), httpClient, nil); err != nil {
-				klog.Errorf("while reading 'google-dockercfg-url'-specified url: %s, %v", string(url), err)
-			} else {
-				return cfg
-			}
+		contents, err := fetchDockerConfigURL(string(url))
+		if err != nil {
+			klog.Errorf("while reading 'google-dockercfg-url'-specified url: %s, %v", string(url), err)
+		} else if cfg, err := readDockerConfigFileFromBytes(contents); err != nil {
+			klog.Errorf("while parsing 'google-dockercfg-url'-specified url: %s, %v", string(url), err)
 		} else {
-			// TODO(mattmoor): support reading alternate scheme URLs (e.g. gs:// or s3://)
-			klog.Errorf("Unsupported URL scheme: %s", string(url))
+			return cfg
 		}
 	}
 
@@ -223,39 +230,56 @@ type tokenBlob struct {
 	AccessToken string `json:"access_token"`
 }
 
-// Provide implements DockerConfigProvider
+// Provide implements DockerConfigProvider. Only the entries for registries
+// that image is actually hosted on are returned, so the access token isn't
+// handed to every registry hostname this provider knows about on every pull.
 func ProvideContainerRegistry(image string) DockerConfig {
 	cfg := DockerConfig{}
 
-	tokenJSONBlob, err := readURL(metadataToken, httpClient, metadataHeader)
+	matches := matchingRegistryURLs(image)
+	if len(matches) == 0 {
+		return cfg
+	}
+
+	entry, err := containerRegistryEntry(context.Background())
 	if err != nil {
-		klog.Errorf("while reading access token endpoint: %v", err)
+		klog.Errorf("while resolving container registry credentials: %v", err)
 		return cfg
 	}
 
+	// Add our entry only for the container registry URLs that image matched,
+	// instead of every registry this provider knows about.
+	for _, k := range matches {
+		cfg[k] = entry
+	}
+	return cfg
+}
+
+// metadataContainerRegistryEntry reads the access token and email of the
+// node's GCE service account directly from the metadata server. It backs
+// containerRegistryEntry's fallback path for bare GCE VMs that have no
+// ambient credentials configured for cloud.google.com/go/auth to resolve.
+func metadataContainerRegistryEntry() (DockerConfigEntry, error) {
+	tokenJSONBlob, err := readURL(metadataToken, httpClient, metadataHeader)
+	if err != nil {
+		return DockerConfigEntry{}, fmt.Errorf("while reading access token endpoint: %w", err)
+	}
+
 	email, err := readURL(metadataEmail, httpClient, metadataHeader)
 	if err != nil {
-		klog.Errorf("while reading email endpoint: %v", err)
-		return cfg
+		return DockerConfigEntry{}, fmt.Errorf("while reading email endpoint: %w", err)
 	}
 
 	var parsedBlob tokenBlob
-	if err := json.Unmarshal([]byte(tokenJSONBlob), &parsedBlob); err != nil {
-		klog.Errorf("while parsing json blob %s: %v", tokenJSONBlob, err)
-		return cfg
+	if err := json.Unmarshal(tokenJSONBlob, &parsedBlob); err != nil {
+		return DockerConfigEntry{}, fmt.Errorf("while parsing json blob %s: %w", tokenJSONBlob, err)
 	}
 
-	entry := DockerConfigEntry{
+	return DockerConfigEntry{
 		Username: "_token",
 		Password: parsedBlob.AccessToken,
 		Email:    string(email),
-	}
-
-	// Add our entry for each of the supported container registry URLs
-	for _, k := range containerRegistryUrls {
-		cfg[k] = entry
-	}
-	return cfg
+	}, nil
 }
 
 // readURL read contents from given url
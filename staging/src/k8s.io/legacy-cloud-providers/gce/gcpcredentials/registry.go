@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import "strings"
+
+// registryHostname returns the registry hostname an image reference will be
+// pulled from, e.g. "gcr.io" for "gcr.io/my-project/my-image:v1" and
+// "us-central1-docker.pkg.dev" for
+// "us-central1-docker.pkg.dev/my-project/my-repo/my-image@sha256:...".
+// References with no registry component (e.g. "busybox", "library/busybox")
+// are assumed to come from the default Docker Hub registry and do not
+// belong to any GCP registry.
+func registryHostname(image string) string {
+	firstSlash := strings.Index(image, "/")
+	if firstSlash == -1 {
+		return ""
+	}
+	host := image[:firstSlash]
+
+	// A bare repo path component (no dot, no colon, and not "localhost")
+	// isn't a registry hostname, e.g. "library/busybox".
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return ""
+	}
+	return host
+}
+
+// matchesRegistryGlob reports whether host matches glob, where glob may use
+// "*" to wildcard exactly one leading label, mirroring the matching rules
+// documented on containerRegistryUrls: "*.gcr.io" matches "foo.gcr.io" but
+// not "foo.bar.gcr.io" or "gcr.io" itself.
+func matchesRegistryGlob(glob, host string) bool {
+	if !strings.HasPrefix(glob, "*.") {
+		return glob == host
+	}
+
+	suffix := glob[1:] // ".gcr.io"
+	if !strings.HasSuffix(host, suffix) {
+		return false
+	}
+	label := strings.TrimSuffix(host, suffix)
+	return label != "" && !strings.Contains(label, ".")
+}
+
+// matchingRegistryURLs returns the subset of containerRegistryUrls that
+// image's registry hostname matches. An empty result means image isn't
+// pulled from any registry this provider is configured for, and no
+// credentials should be offered for it.
+func matchingRegistryURLs(image string) []string {
+	host := registryHostname(image)
+	if host == "" {
+		return nil
+	}
+
+	var matches []string
+	for _, glob := range containerRegistryUrls {
+		if matchesRegistryGlob(glob, host) {
+			matches = append(matches, glob)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import "testing"
+
+func TestRegistryHostname(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"gcr.io/my-project/my-image:v1", "gcr.io"},
+		{"us-central1-docker.pkg.dev/my-project/my-repo/my-image@sha256:deadbeef", "us-central1-docker.pkg.dev"},
+		{"busybox", ""},
+		{"busybox:latest", ""},
+		{"library/busybox", ""},
+		{"localhost:5000/my-image", "localhost:5000"},
+	}
+
+	for _, tt := range tests {
+		if got := registryHostname(tt.image); got != tt.want {
+			t.Errorf("registryHostname(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestMatchingRegistryURLs(t *testing.T) {
+	tests := []struct {
+		image string
+		want  []string
+	}{
+		{"gcr.io/my-project/my-image:v1", []string{"gcr.io"}},
+		{"foo.gcr.io/my-project/my-image:v1", []string{"*.gcr.io"}},
+		{"us-central1-docker.pkg.dev/my-project/my-repo/my-image:v1", []string{"*.pkg.dev"}},
+		{"quay.io/my-project/my-image:v1", nil},
+		{"busybox", nil},
+	}
+
+	for _, tt := range tests {
+		got := matchingRegistryURLs(tt.image)
+		if len(got) != len(tt.want) {
+			t.Errorf("matchingRegistryURLs(%q) = %v, want %v", tt.image, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("matchingRegistryURLs(%q) = %v, want %v", tt.image, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DockerConfigURLFetchFunc fetches the raw bytes of a docker config file
+// located at url, in whatever way is appropriate for the scheme it was
+// registered under.
+type DockerConfigURLFetchFunc func(url string) ([]byte, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]DockerConfigURLFetchFunc{}
+)
+
+// RegisterDockerConfigURLScheme registers fetch as the handler for docker
+// config URLs using the given scheme (e.g. "gs", "s3", "oci"). Out-of-tree
+// consumers of this package can call this from an init function to teach
+// ProvideDockerConfigURLKey how to read a 'google-dockercfg-url' metadata
+// value that points at a scheme this package doesn't natively support.
+// Registering a scheme that is already registered replaces its handler.
+func RegisterDockerConfigURLScheme(scheme string, fetch DockerConfigURLFetchFunc) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[scheme] = fetch
+}
+
+func init() {
+	RegisterDockerConfigURLScheme("http", httpFetch)
+	RegisterDockerConfigURLScheme("https", httpFetch)
+	RegisterDockerConfigURLScheme("gs", gsFetch)
+	RegisterDockerConfigURLScheme("file", fileFetch)
+}
+
+// fetchDockerConfigURL dispatches rawURL to the fetch func registered for
+// its scheme. A bare "host/path" URL with no "scheme://" prefix is treated
+// as "https://" for backwards compatibility with existing
+// 'google-dockercfg-url' values.
+func fetchDockerConfigURL(rawURL string) ([]byte, error) {
+	scheme, _, found := strings.Cut(rawURL, "://")
+	if !found {
+		scheme = "https"
+	}
+
+	schemesMu.RLock()
+	fetch, ok := schemes[scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported URL scheme %q in %q", scheme, rawURL)
+	}
+	return fetch(rawURL)
+}
+
+func httpFetch(rawURL string) ([]byte, error) {
+	return readURL(rawURL, httpClient, nil)
+}
+
+// gsFetch reads a gs:// URL by translating it into an authenticated HTTPS
+// GET against the Google Cloud Storage JSON API, using the GCE metadata
+// server's access token the same way containerRegistryProvider does.
+func gsFetch(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", rawURL, err)
+	}
+	bucket := u.Host
+	object := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid gs:// URL %q, expected gs://bucket/object", rawURL)
+	}
+
+	tokenJSONBlob, err := readURL(metadataToken, httpClient, metadataHeader)
+	if err != nil {
+		return nil, fmt.Errorf("fetching access token to read %q: %w", rawURL, err)
+	}
+	var parsedBlob tokenBlob
+	if err := json.Unmarshal(tokenJSONBlob, &parsedBlob); err != nil {
+		return nil, fmt.Errorf("parsing access token to read %q: %w", rawURL, err)
+	}
+
+	downloadURL := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media",
+		url.PathEscape(bucket), url.PathEscape(object))
+	header := &http.Header{
+		"Authorization": []string{"Bearer " + parsedBlob.AccessToken},
+	}
+	return readURL(downloadURL, httpClient, header)
+}
+
+// fileFetch reads a file:// URL from the node's local filesystem, for
+// on-node testing of alternate docker config sources without a metadata
+// server.
+func fileFetch(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", rawURL, err)
+	}
+	return ioutil.ReadFile(u.Path)
+}
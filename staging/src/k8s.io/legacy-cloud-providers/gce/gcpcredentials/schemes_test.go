@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcpcredentials
+
+import "testing"
+
+func TestFetchDockerConfigURLBareHostDefaultsToHTTPS(t *testing.T) {
+	var gotURL string
+	RegisterDockerConfigURLScheme("https", func(rawURL string) ([]byte, error) {
+		gotURL = rawURL
+		return []byte("ok"), nil
+	})
+	defer RegisterDockerConfigURLScheme("https", httpFetch)
+
+	body, err := fetchDockerConfigURL("example.com/dockercfg")
+	if err != nil {
+		t.Fatalf("fetchDockerConfigURL returned error: %v", err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("fetchDockerConfigURL body = %q, want %q", body, "ok")
+	}
+	if gotURL != "example.com/dockercfg" {
+		t.Errorf("expected the https handler to receive the raw URL unchanged, got %q", gotURL)
+	}
+}
+
+func TestFetchDockerConfigURLUnknownScheme(t *testing.T) {
+	_, err := fetchDockerConfigURL("s3://bucket/object")
+	if err == nil {
+		t.Fatal("expected an error for an unregistered scheme")
+	}
+}
+
+func TestGSFetchMissingBucket(t *testing.T) {
+	_, err := gsFetch("gs:///object")
+	if err == nil {
+		t.Fatal("expected an error for a gs:// URL with no bucket")
+	}
+}
+
+func TestGSFetchMissingObject(t *testing.T) {
+	_, err := gsFetch("gs://bucket")
+	if err == nil {
+		t.Fatal("expected an error for a gs:// URL with no object")
+	}
+}